@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"fmt"
+	"github.com/prodyna/sync-enterprise/sync"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	keyGitHubActions     = "GITHUB_ACTIONS"
+	keyGitHubStepSummary = "GITHUB_STEP_SUMMARY"
+	keyGitHubOutput      = "GITHUB_OUTPUT"
+)
+
+// Running returns if the binary runs inside GitHub Actions
+func Running() bool {
+	return os.Getenv(keyGitHubActions) == "true"
+}
+
+// WriteSummary appends a Markdown table of the invited, updated, deleted and failed users to the job summary
+func WriteSummary(r *sync.Report) error {
+	path := os.Getenv(keyGitHubStepSummary)
+	if path == "" {
+		return nil
+	}
+
+	b := strings.Builder{}
+	b.WriteString("## Sync enterprise\n\n")
+	if r.DryRun {
+		b.WriteString("Dry-run, no changes were made.\n\n")
+	}
+
+	classifications := []string{}
+	for c := range r.Summary {
+		classifications = append(classifications, c)
+	}
+	sort.Strings(classifications)
+	for _, c := range classifications {
+		fmt.Fprintf(&b, "* %s: %d\n", c, r.Summary[c])
+	}
+	b.WriteString("\n")
+
+	rows := 0
+	for _, e := range r.Entries {
+		switch e.Classification {
+		case sync.ClassInvite, sync.ClassDelete, sync.ClassUpdate, sync.ClassOrgAdd, sync.ClassOrgRemove, sync.ClassOrgRole,
+			sync.ClassTeamAdd, sync.ClassTeamRemove, sync.ClassTeamRole, sync.ClassTeamCreate,
+			sync.ClassGroupAdd, sync.ClassGroupRemove, sync.ClassGroupCreate,
+			sync.ClassDryRun, sync.ClassSkipped, sync.ClassFailed:
+		default:
+			continue
+		}
+		if rows == 0 {
+			b.WriteString("| Classification | Action | Login | Organization | Team | Group | Email | Name | Reason | Outcome |\n")
+			b.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+		}
+		outcome := e.Outcome
+		if e.Error != "" {
+			outcome = e.Error
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			e.Classification, cell(e.Action), cell(e.Login), cell(e.Organization), cell(e.Team), cell(e.Group), cell(e.Email), cell(e.DisplayName), cell(e.Reason), cell(outcome))
+		rows++
+	}
+	if rows == 0 {
+		b.WriteString("Nobody was invited, updated or removed.\n")
+	}
+
+	return appendFile(path, b.String())
+}
+
+// WriteOutputs sets the step outputs
+func WriteOutputs(outputs map[string]string) error {
+	path := os.Getenv(keyGitHubOutput)
+	if path == "" {
+		return nil
+	}
+
+	names := []string{}
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := strings.Builder{}
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, outputs[name])
+	}
+	return appendFile(path, b.String())
+}
+
+func appendFile(path string, content string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// cell makes the value safe to be used in a Markdown table cell
+func cell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,62 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	keyIdTokenRequestUrl   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	keyIdTokenRequestToken = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// IDToken requests an OIDC ID token for the audience from GitHub Actions.
+// The workflow needs the permission id-token: write.
+func IDToken(ctx context.Context, audience string) (string, error) {
+	requestUrl := os.Getenv(keyIdTokenRequestUrl)
+	requestToken := os.Getenv(keyIdTokenRequestToken)
+	if requestUrl == "" || requestToken == "" {
+		return "", errors.New("no GitHub Actions ID token available, the workflow needs the permission id-token: write")
+	}
+
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", fmt.Errorf("error parsing %s: %w", keyIdTokenRequestUrl, err)
+	}
+	query := u.Query()
+	query.Set("audience", audience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting ID token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting ID token: %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("error decoding ID token: %w", err)
+	}
+	if body.Value == "" {
+		return "", errors.New("empty ID token")
+	}
+	return body.Value, nil
+}
@@ -2,120 +2,319 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	msgraph "github.com/microsoftgraph/msgraph-sdk-go"
 	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	"github.com/microsoftgraph/msgraph-sdk-go/groups"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/prodyna/sync-enterprise/sync"
 	"log/slog"
 	"strings"
 )
 
+// ErrInvalidConfig is returned by New if a file or value of the configuration can not be used
+var ErrInvalidConfig = errors.New("invalid configuration")
+
 type Config struct {
 	AzureTenantId     string
 	AzureClientId     string
 	AzureClientSecret string
-	AzureGroup        string
+	// AzureAuth selects the credential, see the Auth constants
+	AzureAuth                string
+	AzureCertificateFile     string
+	AzureCertificatePassword string
+	// AzureGroups are the groups whose members are desired
+	AzureGroups []string
+	// AzureExcludeGroups are the groups whose members are never desired
+	AzureExcludeGroups []string
+	// AzureTransitive resolves the members of nested groups
+	AzureTransitive bool
+	// AzureMaxRetries is the number of retries of throttled or unavailable Graph requests
+	AzureMaxRetries int
 }
 
 type Azure struct {
-	Config   Config
-	azclient *msgraph.GraphServiceClient
-	users    AzureUsers
+	Config     Config
+	azclient   *msgraph.GraphServiceClient
+	users      AzureUsers
+	groupNames map[string]string
 }
 
 type AzureUser struct {
-	Email       string
-	DisplayName string
+	ObjectId          string
+	Email             string
+	DisplayName       string
+	UserPrincipalName string
+	ProxyAddresses    []string
+	OtherMails        []string
+	EmployeeId        string
+	GivenName         string
+	Surname           string
+	// UserType is either Member or Guest
+	UserType       string
+	AccountEnabled bool
+	// Groups are the names of the groups that granted the access
+	Groups []string
+	// Path are the names of the nested groups from the granting group to the direct group
+	Path []string
 }
 
 type AzureUsers []AzureUser
 
 func New(ctx context.Context, config Config) (*Azure, error) {
 	az := Azure{
-		Config: config,
+		Config:     config,
+		groupNames: map[string]string{},
 	}
 
-	cred, err := azidentity.NewClientSecretCredential(
-		config.AzureTenantId,
-		config.AzureClientId,
-		config.AzureClientSecret,
-		&azidentity.ClientSecretCredentialOptions{})
+	cred, err := newCredential(config)
 	if err != nil {
 		return nil, err
 	}
 
-	az.azclient, err = msgraph.NewGraphServiceClientWithCredentials(cred, []string{})
+	az.azclient, err = newGraphClient(cred, config.AzureMaxRetries)
 	if err != nil {
 		return nil, err
 	}
 
-	// try to connect to the group
-	group, err := az.azclient.Groups().ByGroupId(config.AzureGroup).Get(ctx, nil)
-	if err != nil {
-		return nil, err
+	// try to connect to the groups
+	for _, groupId := range append(append([]string{}, config.AzureGroups...), config.AzureExcludeGroups...) {
+		err = az.connectGroup(ctx, groupId)
+		if err != nil {
+			return nil, err
+		}
 	}
-	slog.Info("Connected to group", "group", *group.GetDisplayName())
 
 	return &az, nil
 }
 
+// connectGroup loads the name of the group
+func (az *Azure) connectGroup(ctx context.Context, groupId string) error {
+	group, err := az.azclient.Groups().ByGroupId(groupId).Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error getting group %s: %w", groupId, err)
+	}
+	az.groupNames[groupId] = groupId
+	if group.GetDisplayName() != nil {
+		az.groupNames[groupId] = *group.GetDisplayName()
+	}
+	slog.Info("Connected to group", "group", az.groupNames[groupId], "groupId", groupId)
+	return nil
+}
+
+// Users returns the members of all groups without the members of the exclude groups
 func (az *Azure) Users(ctx context.Context) ([]AzureUser, error) {
 	if az.users == nil {
 		users := []AzureUser{}
+		byId := map[string]int{}
 
-		top := int32(999)
-		query := groups.ItemMembersGraphUserRequestBuilderGetQueryParameters{
-			Select: []string{"id", "displayName", "mail"},
-			Top:    &top,
+		for _, groupId := range az.Config.AzureGroups {
+			members, err := az.groupMembers(ctx, groupId)
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range members {
+				if i, ok := byId[member.ObjectId]; ok {
+					users[i].Groups = append(users[i].Groups, az.groupNames[groupId])
+					continue
+				}
+				member.Groups = []string{az.groupNames[groupId]}
+				byId[member.ObjectId] = len(users)
+				users = append(users, member)
+			}
 		}
 
-		options := &groups.ItemMembersGraphUserRequestBuilderGetRequestConfiguration{
-			QueryParameters: &query,
+		excluded := map[string]bool{}
+		for _, groupId := range az.Config.AzureExcludeGroups {
+			members, err := az.groupMembers(ctx, groupId)
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range members {
+				if _, ok := byId[member.ObjectId]; ok && !excluded[member.ObjectId] {
+					slog.Debug("Azure user excluded",
+						"email", member.Email,
+						"displayName", member.DisplayName,
+						"group", az.groupNames[groupId])
+				}
+				excluded[member.ObjectId] = true
+			}
 		}
 
-		result, err := az.azclient.Groups().ByGroupId(az.Config.AzureGroup).Members().GraphUser().Get(ctx, options)
-		if err != nil {
-			return nil, fmt.Errorf("error getting group members: %w", err)
+		az.users = AzureUsers{}
+		for _, user := range users {
+			if !excluded[user.ObjectId] {
+				az.users = append(az.users, user)
+			}
 		}
+		slog.Info("Loaded Azure users",
+			"users", len(az.users),
+			"groups", len(az.Config.AzureGroups),
+			"excludeGroups", len(az.Config.AzureExcludeGroups))
+	}
 
-		pageIterator, err := msgraphgocore.NewPageIterator[*models.User](result, az.azclient.GetAdapter(), models.CreateUserCollectionResponseFromDiscriminatorValue)
-		if err != nil {
-			return nil, fmt.Errorf("error creating page iterator: %w", err)
-		}
+	return az.users, nil
+}
 
-		err = pageIterator.Iterate(ctx, func(user *models.User) bool {
-			if user != nil {
-				slog.Debug("Azure group member",
-					"email", *user.GetMail(),
-					"displayName", *user.GetDisplayName())
-				users = append(users, AzureUser{
-					Email:       *user.GetMail(),
-					DisplayName: *user.GetDisplayName(),
-				})
-			}
+// directUsers returns the users that are direct members of the group
+func (az *Azure) directUsers(ctx context.Context, groupId string) ([]AzureUser, error) {
+	users := []AzureUser{}
+
+	top := int32(999)
+	query := groups.ItemMembersGraphUserRequestBuilderGetQueryParameters{
+		Select: []string{"id", "displayName", "mail", "userPrincipalName", "userType", "accountEnabled", "proxyAddresses", "otherMails", "employeeId", "givenName", "surname"},
+		Top:    &top,
+	}
+
+	options := &groups.ItemMembersGraphUserRequestBuilderGetRequestConfiguration{
+		QueryParameters: &query,
+	}
+
+	result, err := az.azclient.Groups().ByGroupId(groupId).Members().GraphUser().Get(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("error getting members of group %s: %w", az.groupNames[groupId], err)
+	}
+
+	pageIterator, err := msgraphgocore.NewPageIterator[*models.User](result, az.azclient.GetAdapter(), models.CreateUserCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return nil, fmt.Errorf("error creating page iterator: %w", err)
+	}
+
+	err = pageIterator.Iterate(ctx, func(user *models.User) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if user == nil {
+			return true
+		}
+		if user.GetId() == nil {
+			slog.Warn("Skipping Azure group member without object ID", "groupId", groupId)
 			return true
-		})
+		}
 
-		az.users = users
+		azureUser := AzureUser{
+			ObjectId:          *user.GetId(),
+			Email:             stringValue(user.GetMail()),
+			DisplayName:       stringValue(user.GetDisplayName()),
+			UserPrincipalName: stringValue(user.GetUserPrincipalName()),
+			UserType:          stringValue(user.GetUserType()),
+			EmployeeId:        stringValue(user.GetEmployeeId()),
+			GivenName:         stringValue(user.GetGivenName()),
+			Surname:           stringValue(user.GetSurname()),
+			AccountEnabled:    true,
+			ProxyAddresses:    user.GetProxyAddresses(),
+			OtherMails:        user.GetOtherMails(),
+		}
+		if azureUser.DisplayName == "" {
+			azureUser.DisplayName = azureUser.UserPrincipalName
+		}
+		if user.GetAccountEnabled() != nil {
+			azureUser.AccountEnabled = *user.GetAccountEnabled()
+		}
+		slog.Debug("Azure group member",
+			"email", azureUser.Email,
+			"displayName", azureUser.DisplayName,
+			"objectId", azureUser.ObjectId,
+			"groupId", groupId)
+		users = append(users, azureUser)
+		return true
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		// a partial member list would delete the missing members
+		slog.Error("Unable to load all members of Azure group", "group", az.groupNames[groupId], "loaded", len(users), "error", err)
+		return nil, fmt.Errorf("error iterating members of group %s: %w", az.groupNames[groupId], err)
 	}
 
-	return az.users, nil
+	return users, nil
 }
 
-func (az *Azure) IsUserInGroup(ctx context.Context, email string) (isInGroup bool, displayName *string, err error) {
+// Identities returns the group members as sync identities
+func (az *Azure) Identities(ctx context.Context) ([]sync.Identity, error) {
 	users, err := az.Users(ctx)
 	if err != nil {
-		return false, nil, err
+		return nil, err
 	}
-	emailLC := strings.ToLower(email)
 
+	identities := []sync.Identity{}
 	for _, user := range users {
-		if strings.ToLower(user.Email) == emailLC {
-			return true, &user.DisplayName, nil
+		identities = append(identities, user.identity())
+	}
+	return identities, nil
+}
+
+// identity converts the user into a sync identity
+func (user AzureUser) identity() sync.Identity {
+	addresses := append([]string{}, user.OtherMails...)
+	for _, address := range user.ProxyAddresses {
+		// proxy addresses are prefixed with the protocol, e.g. SMTP:jane@example.com
+		if len(address) > 5 && strings.EqualFold(address[:5], "smtp:") {
+			addresses = append(addresses, address[5:])
 		}
 	}
+	return sync.Identity{
+		ObjectId:          user.ObjectId,
+		Email:             user.Email,
+		DisplayName:       user.DisplayName,
+		UserPrincipalName: user.UserPrincipalName,
+		GivenName:         user.GivenName,
+		Surname:           user.Surname,
+		Addresses:         addresses,
+		EmployeeId:        user.EmployeeId,
+		Groups:            user.Groups,
+		Disabled:          !user.AccountEnabled,
+		Guest:             strings.EqualFold(user.UserType, "Guest"),
+	}
+}
 
-	return false, nil, nil
+// GroupIdentities returns the desired users that are members of the group
+func (az *Azure) GroupIdentities(ctx context.Context, groupId string) ([]sync.Identity, error) {
+	users, err := az.Users(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := az.groupNames[groupId]; !ok {
+		err = az.connectGroup(ctx, groupId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := az.groupMembers(ctx, groupId)
+	if err != nil {
+		return nil, err
+	}
+	inGroup := map[string]bool{}
+	for _, member := range members {
+		inGroup[member.ObjectId] = true
+	}
+
+	identities := []sync.Identity{}
+	for _, user := range users {
+		if inGroup[user.ObjectId] {
+			identities = append(identities, user.identity())
+		}
+	}
+	slog.Info("Loaded Azure group", "group", az.groupNames[groupId], "members", len(members), "desired", len(identities))
+	return identities, nil
+}
+
+// Groups returns the names of the groups whose members are desired by group ID
+func (az *Azure) Groups() map[string]string {
+	groups := map[string]string{}
+	for _, groupId := range az.Config.AzureGroups {
+		groups[groupId] = az.groupNames[groupId]
+	}
+	return groups
+}
+
+// stringValue returns the value of an optional Graph attribute or an empty string
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
 }
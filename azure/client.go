@@ -0,0 +1,53 @@
+package azure
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	kiotaauth "github.com/microsoft/kiota-authentication-azure-go"
+	khttp "github.com/microsoft/kiota-http-go"
+	msgraph "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// retryDelaySeconds is the base of the exponential backoff if Graph sends no Retry-After
+const retryDelaySeconds = 2
+
+// newGraphClient creates a Graph client whose retry middleware retries throttled (429) and
+// unavailable (503, 504) requests, honoring Retry-After and otherwise backing off exponentially.
+func newGraphClient(cred azcore.TokenCredential, maxRetries int) (*msgraph.GraphServiceClient, error) {
+	auth, err := kiotaauth.NewAzureIdentityAuthenticationProviderWithScopes(cred, []string{"https://graph.microsoft.com/.default"})
+	if err != nil {
+		return nil, err
+	}
+
+	retry := khttp.NewRetryHandlerWithOptions(khttp.RetryHandlerOptions{
+		MaxRetries:   maxRetries,
+		DelaySeconds: retryDelaySeconds,
+		ShouldRetry: func(delay time.Duration, executionCount int, request *http.Request, response *http.Response) bool {
+			slog.Warn("Graph request throttled or unavailable, retrying",
+				"method", request.Method,
+				"url", request.URL.Path,
+				"status", response.StatusCode,
+				"retryAfter", response.Header.Get("Retry-After"),
+				"attempt", executionCount+1)
+			return true
+		},
+	})
+
+	options := msgraph.GetDefaultClientOptions()
+	middlewares := msgraphgocore.GetDefaultMiddlewaresWithOptions(&options)
+	for i, middleware := range middlewares {
+		if _, ok := middleware.(*khttp.RetryHandler); ok {
+			middlewares[i] = retry
+		}
+	}
+	httpClient := msgraphgocore.GetDefaultClient(&options, middlewares...)
+
+	adapter, err := msgraph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(auth, nil, nil, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return msgraph.NewGraphServiceClient(adapter), nil
+}
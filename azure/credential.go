@@ -0,0 +1,74 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/prodyna/sync-enterprise/actions"
+	"os"
+)
+
+const (
+	// AuthSecret authenticates the app registration with a client secret
+	AuthSecret = "secret"
+	// AuthCertificate authenticates the app registration with a client certificate
+	AuthCertificate = "certificate"
+	// AuthManagedIdentity uses the managed identity of the host
+	AuthManagedIdentity = "managed-identity"
+	// AuthCLI uses the login of the Azure CLI, meant for local runs
+	AuthCLI = "cli"
+	// AuthOIDC federates the GitHub Actions ID token with the app registration
+	AuthOIDC = "oidc"
+)
+
+// oidcAudience is the audience Entra ID expects for federated credentials
+const oidcAudience = "api://AzureADTokenExchange"
+
+// newCredential creates the credential for the configured authentication mode
+func newCredential(config Config) (azcore.TokenCredential, error) {
+	switch config.AzureAuth {
+	case "", AuthSecret:
+		return azidentity.NewClientSecretCredential(
+			config.AzureTenantId,
+			config.AzureClientId,
+			config.AzureClientSecret,
+			&azidentity.ClientSecretCredentialOptions{})
+	case AuthCertificate:
+		data, err := os.ReadFile(config.AzureCertificateFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: error reading certificate %s: %w", ErrInvalidConfig, config.AzureCertificateFile, err)
+		}
+		certs, key, err := azidentity.ParseCertificates(data, []byte(config.AzureCertificatePassword))
+		if err != nil {
+			return nil, fmt.Errorf("%w: error parsing certificate %s: %w", ErrInvalidConfig, config.AzureCertificateFile, err)
+		}
+		return azidentity.NewClientCertificateCredential(
+			config.AzureTenantId,
+			config.AzureClientId,
+			certs,
+			key,
+			&azidentity.ClientCertificateCredentialOptions{})
+	case AuthManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if config.AzureClientId != "" {
+			// user assigned identity, otherwise the system assigned identity is used
+			options.ID = azidentity.ClientID(config.AzureClientId)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case AuthCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+			TenantID: config.AzureTenantId,
+		})
+	case AuthOIDC:
+		return azidentity.NewClientAssertionCredential(
+			config.AzureTenantId,
+			config.AzureClientId,
+			func(ctx context.Context) (string, error) {
+				return actions.IDToken(ctx, oidcAudience)
+			},
+			&azidentity.ClientAssertionCredentialOptions{})
+	default:
+		return nil, fmt.Errorf("%w: unknown Azure authentication %s", ErrInvalidConfig, config.AzureAuth)
+	}
+}
@@ -0,0 +1,127 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	msgraphgocore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/groups"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"log/slog"
+)
+
+type azureGroup struct {
+	id          string
+	displayName string
+}
+
+// groupMembers returns the members of the group, including the members of nested groups if configured
+func (az *Azure) groupMembers(ctx context.Context, groupId string) ([]AzureUser, error) {
+	users := []AzureUser{}
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+
+	err := az.collectMembers(ctx, groupId, []string{az.groupNames[groupId]}, visited, seen, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// collectMembers adds the users of the group and, if transitive, of all nested groups.
+// Every group is expanded only once, which also stops cycles.
+func (az *Azure) collectMembers(ctx context.Context, groupId string, path []string, visited map[string]bool, seen map[string]bool, users *[]AzureUser) error {
+	visited[groupId] = true
+
+	direct, err := az.directUsers(ctx, groupId)
+	if err != nil {
+		return err
+	}
+	for _, user := range direct {
+		if seen[user.ObjectId] {
+			continue
+		}
+		seen[user.ObjectId] = true
+		user.Path = append([]string{}, path...)
+		if len(path) > 1 {
+			slog.Debug("Azure nested group member",
+				"email", user.Email,
+				"displayName", user.DisplayName,
+				"path", user.Path)
+		}
+		*users = append(*users, user)
+	}
+
+	if !az.Config.AzureTransitive {
+		return nil
+	}
+
+	nested, err := az.directGroups(ctx, groupId)
+	if err != nil {
+		return err
+	}
+	for _, group := range nested {
+		if visited[group.id] {
+			slog.Debug("Skipping nested group that was already expanded",
+				"group", group.displayName,
+				"path", path)
+			continue
+		}
+		slog.Debug("Expanding nested group", "group", group.displayName, "path", path)
+		err = az.collectMembers(ctx, group.id, append(append([]string{}, path...), group.displayName), visited, seen, users)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// directGroups returns the groups that are direct members of the group
+func (az *Azure) directGroups(ctx context.Context, groupId string) ([]azureGroup, error) {
+	nested := []azureGroup{}
+
+	top := int32(999)
+	query := groups.ItemMembersGraphGroupRequestBuilderGetQueryParameters{
+		Select: []string{"id", "displayName"},
+		Top:    &top,
+	}
+
+	options := &groups.ItemMembersGraphGroupRequestBuilderGetRequestConfiguration{
+		QueryParameters: &query,
+	}
+
+	result, err := az.azclient.Groups().ByGroupId(groupId).Members().GraphGroup().Get(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("error getting nested groups: %w", err)
+	}
+
+	pageIterator, err := msgraphgocore.NewPageIterator[*models.Group](result, az.azclient.GetAdapter(), models.CreateGroupCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return nil, fmt.Errorf("error creating page iterator: %w", err)
+	}
+
+	err = pageIterator.Iterate(ctx, func(group *models.Group) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if group != nil && group.GetId() != nil {
+			g := azureGroup{
+				id:          *group.GetId(),
+				displayName: *group.GetId(),
+			}
+			if group.GetDisplayName() != nil {
+				g.displayName = *group.GetDisplayName()
+			}
+			nested = append(nested, g)
+		}
+		return true
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error iterating nested groups of %s: %w", az.groupNames[groupId], err)
+	}
+
+	return nested, nil
+}
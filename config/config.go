@@ -3,10 +3,13 @@ package config
 import (
 	"errors"
 	"flag"
-	"log"
+	"fmt"
 	"log/slog"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,70 +19,535 @@ const (
 	keyAzureClientSecret = "azure-client-secret"
 	keyAzureTenantId     = "azure-tenant-id"
 	keyAzureGroup        = "azure-group"
+	keyAzureExcludeGroup = "azure-exclude-group"
+	keyAzureTransitive   = "azure-transitive"
+	keyAzureMaxRetries   = "azure-max-retries"
+	keyAzureAuth         = "azure-auth"
+	keyAzureCertificate  = "azure-client-certificate"
 	keyDryRun            = "dry-run"
 
-	keyGitHubEnterpriseEnvironment  = "GITHUB_ENTERPRISE"
-	keyGitHubTokenEnvironment       = "GITHUB_TOKEN"
-	keyAzureClientIdEnvironment     = "AZURE_CLIENT_ID"
-	keyAzureClientSecretEnvironment = "AZURE_CLIENT_SECRET"
-	keyAzureTenantIdEnvironment     = "AZURE_TENANT_ID"
-	keyAzureGroupEnvironment        = "AZURE_GROUP"
-	keyDryRunEnvironment            = "DRY_RUN"
+	keyGithubInviteOrganizations = "github-invite-organizations"
+	keyGithubInviteRole          = "github-invite-role"
+	keyGithubInviteTeamIds       = "github-invite-team-ids"
+	keyGithubMaxRetries          = "github-max-retries"
+	keyGithubAppId               = "github-app-id"
+	keyGithubAppPrivateKeyFile   = "github-app-private-key-file"
+	keyGithubAppInstallationId   = "github-app-installation-id"
+	keyGithubHost                = "github-host"
+	keyGithubApiUrl              = "github-api-url"
+	keyGithubGraphqlUrl          = "github-graphql-url"
+	keyGithubCaBundle            = "github-ca-bundle"
+	keyGithubMode                = "github-mode"
+	keyPlanFile                  = "plan-file"
+	keyMaxDeleteCount            = "max-delete-count"
+	keyMaxDeletePercent          = "max-delete-percent"
+	keyAllowMassDelete           = "allow-mass-delete"
+	keyMaxUnmatchable            = "max-unmatchable"
+	keyProtectedLogins           = "protected-logins"
+	keyProtectedEmails           = "protected-emails"
+	keyProtectedPatterns         = "protected-patterns"
+	keyProtectOwners             = "protect-enterprise-owners"
+	keyRemoveDisabled            = "remove-disabled"
+	keyRemoveGuests              = "remove-guests"
+	keyMatchStrategies           = "match-strategies"
+	keyReport                    = "report"
+	keyVerbose                   = "verbose"
+	keyLogFormat                 = "log-format"
+	keyFailFast                  = "fail-fast"
+	keyOrgMappings               = "org-mappings"
+	keyTeamMappings              = "team-mappings"
+	keyCreateTeams               = "create-teams"
+	keyGracePeriod               = "grace-period"
+	keyStateFile                 = "state-file"
+	keyStateRepository           = "state-repository"
+	keyStateBranch               = "state-branch"
+	keySnapshotDir               = "snapshot-dir"
+	keyRestoreFile               = "restore-file"
+	keyInactiveDays              = "inactive-days"
+	keyRemoveInactive            = "remove-inactive"
+
+	keyGitHubEnterpriseEnvironment         = "GITHUB_ENTERPRISE"
+	keyGitHubTokenEnvironment              = "GITHUB_TOKEN"
+	keyAzureClientIdEnvironment            = "AZURE_CLIENT_ID"
+	keyAzureClientSecretEnvironment        = "AZURE_CLIENT_SECRET"
+	keyAzureTenantIdEnvironment            = "AZURE_TENANT_ID"
+	keyAzureGroupEnvironment               = "AZURE_GROUP"
+	keyAzureExcludeGroupEnvironment        = "AZURE_EXCLUDE_GROUP"
+	keyAzureTransitiveEnvironment          = "AZURE_TRANSITIVE"
+	keyAzureMaxRetriesEnvironment          = "AZURE_MAX_RETRIES"
+	keyAzureAuthEnvironment                = "AZURE_AUTH"
+	keyAzureCertificateEnvironment         = "AZURE_CLIENT_CERTIFICATE_PATH"
+	keyAzureCertificatePasswordEnvironment = "AZURE_CLIENT_CERTIFICATE_PASSWORD"
+	keyDryRunEnvironment                   = "DRY_RUN"
+
+	keyGithubInviteOrganizationsEnvironment = "GITHUB_INVITE_ORGANIZATIONS"
+	keyGithubInviteRoleEnvironment          = "GITHUB_INVITE_ROLE"
+	keyGithubInviteTeamIdsEnvironment       = "GITHUB_INVITE_TEAM_IDS"
+	keyGithubMaxRetriesEnvironment          = "GITHUB_MAX_RETRIES"
+	keyGithubAppIdEnvironment               = "GITHUB_APP_ID"
+	keyGithubAppPrivateKeyFileEnvironment   = "GITHUB_APP_PRIVATE_KEY_FILE"
+	keyGithubAppPrivateKeyEnvironment       = "GITHUB_APP_PRIVATE_KEY"
+	keyGithubAppInstallationIdEnvironment   = "GITHUB_APP_INSTALLATION_ID"
+	keyGithubHostEnvironment                = "GITHUB_HOST"
+	keyGithubApiUrlEnvironment              = "GITHUB_REST_API_URL"    // GITHUB_API_URL is set by the runner
+	keyGithubGraphqlUrlEnvironment          = "GITHUB_GRAPHQL_API_URL" // GITHUB_GRAPHQL_URL is set by the runner
+	keyGithubCaBundleEnvironment            = "GITHUB_CA_BUNDLE"
+	keyGithubModeEnvironment                = "GITHUB_MODE"
+	keyPlanFileEnvironment                  = "PLAN_FILE"
+	keyMaxDeleteCountEnvironment            = "MAX_DELETE_COUNT"
+	keyMaxDeletePercentEnvironment          = "MAX_DELETE_PERCENT"
+	keyAllowMassDeleteEnvironment           = "ALLOW_MASS_DELETE"
+	keyMaxUnmatchableEnvironment            = "MAX_UNMATCHABLE"
+	keyProtectedLoginsEnvironment           = "PROTECTED_LOGINS"
+	keyProtectedEmailsEnvironment           = "PROTECTED_EMAILS"
+	keyProtectedPatternsEnvironment         = "PROTECTED_PATTERNS"
+	keyProtectOwnersEnvironment             = "PROTECT_ENTERPRISE_OWNERS"
+	keyRemoveDisabledEnvironment            = "REMOVE_DISABLED"
+	keyRemoveGuestsEnvironment              = "REMOVE_GUESTS"
+	keyMatchStrategiesEnvironment           = "MATCH_STRATEGIES"
+	keyReportEnvironment                    = "REPORT"
+	keyVerboseEnvironment                   = "VERBOSE"
+	keyLogFormatEnvironment                 = "LOG_FORMAT"
+	keyFailFastEnvironment                  = "FAIL_FAST"
+	keyOrgMappingsEnvironment               = "ORG_MAPPINGS"
+	keyTeamMappingsEnvironment              = "TEAM_MAPPINGS"
+	keyCreateTeamsEnvironment               = "CREATE_TEAMS"
+	keyGracePeriodEnvironment               = "GRACE_PERIOD"
+	keyStateFileEnvironment                 = "STATE_FILE"
+	keyStateRepositoryEnvironment           = "STATE_REPOSITORY"
+	keyStateBranchEnvironment               = "STATE_BRANCH"
+	keySnapshotDirEnvironment               = "SNAPSHOT_DIR"
+	keyRestoreFileEnvironment               = "RESTORE_FILE"
+	keyInactiveDaysEnvironment              = "INACTIVE_DAYS"
+	keyRemoveInactiveEnvironment            = "REMOVE_INACTIVE"
+	keyCommandEnvironment                   = "COMMAND"
+)
+
+const (
+	// CommandSync computes the actions and executes them
+	CommandSync = "sync"
+	// CommandPlan computes the actions and writes them to the plan file
+	CommandPlan = "plan"
+	// CommandApply executes the actions of the plan file
+	CommandApply = "apply"
+	// CommandRestore grants the access of the snapshot file again
+	CommandRestore = "restore"
+)
+
+const (
+	// ModeInvite invites personal accounts into the organizations
+	ModeInvite = "invite"
+	// ModeEMU provisions Enterprise Managed Users with SCIM
+	ModeEMU = "emu"
 )
 
 type GitHub struct {
-	Enterprise string
-	Token      string
+	Enterprise          string
+	Token               string
+	InviteOrganizations []string
+	InviteRole          string
+	InviteTeamIds       []int64
+	MaxRetries          int
+	AppId               int64
+	AppPrivateKey       string
+	AppInstallationId   int64
+	Host                string
+	ApiUrl              string
+	GraphqlUrl          string
+	CaBundle            string
+	// Mode is invite for enterprises with personal accounts or emu for Enterprise Managed Users
+	Mode string
 }
 
 type Azure struct {
-	ClientId     string
-	ClientSecret string
-	TenantId     string
+	ClientId      string
+	ClientSecret  string
+	TenantId      string
+	Groups        []string
+	ExcludeGroups []string
+	Transitive    bool
+	MaxRetries    int
+	// Auth is one of secret, certificate, managed-identity, cli or oidc
+	Auth                string
+	CertificateFile     string
+	CertificatePassword string
+}
+
+type GuardRails struct {
+	MaxDeleteCount   int
+	MaxDeletePercent int
+	MaxUnmatchable   int
+	AllowMassDelete  bool
+}
+
+// State is where the pending removals of the grace period are stored
+type State struct {
+	GracePeriod time.Duration
+	// File is a local path, or the path in the repository if Repository is set
+	File       string
+	Repository string
+	Branch     string
+}
+
+type Protection struct {
+	Logins   []string
+	Emails   []string
+	Patterns []*regexp.Regexp
+	Owners   bool
+}
+
+type Policy struct {
+	RemoveDisabled bool
+	RemoveGuests   bool
+	InactiveDays   int
+	RemoveInactive bool
+}
+
+// OrgMapping grants the members of an Azure group a role in an organization
+type OrgMapping struct {
 	Group        string
+	Organization string
+	Role         string
+}
+
+// TeamMapping grants the members of an Azure group a role in a team of an organization
+type TeamMapping struct {
+	Group        string
+	Organization string
+	Team         string
+	Role         string
+}
+
+// Report is a report file to write after the sync
+type Report struct {
+	Format string
+	Path   string
 }
 
 type Config struct {
-	GitHub GitHub
-	Azure  Azure
-	DryRun bool
+	GitHub     GitHub
+	Azure      Azure
+	GuardRails GuardRails
+	State      State
+	Protection Protection
+	Policy     Policy
+	// MatchStrategies are the names of the strategies to match GitHub and Azure users, tried in order
+	MatchStrategies []string
+	Reports         []Report
+	OrgMappings     []OrgMapping
+	TeamMappings    []TeamMapping
+	CreateTeams     bool
+	// Verbose is the log level, 0=error, 1=warn, 2=info, 3=debug
+	Verbose   int
+	LogFormat string
+	FailFast  bool
+	DryRun    bool
+	Command   string
+	PlanFile  string
+	// SnapshotDir is the directory for snapshots of deleted users, empty disables them
+	SnapshotDir string
+	RestoreFile string
 }
 
+// New returns the configuration of the command line flags and the environment
 func New() (*Config, error) {
+	return parse(os.Args[1:])
+}
+
+// parse returns the configuration of the arguments and the environment, an invalid flag is returned as error
+func parse(args []string) (*Config, error) {
 	c := Config{}
-	flag.StringVar(&c.GitHub.Token, keyGithubToken, lookupEnvOrString(keyGitHubTokenEnvironment, ""), "The GitHub Token to use for authentication.")
-	flag.StringVar(&c.GitHub.Enterprise, keyGithubEnterprise, lookupEnvOrString(keyGitHubEnterpriseEnvironment, ""), "The GitHub Enterprise to query for repositories.")
-	flag.StringVar(&c.Azure.ClientId, keyAzureClientId, lookupEnvOrString(keyAzureClientIdEnvironment, ""), "The Azure Client ID.")
-	flag.StringVar(&c.Azure.ClientSecret, keyAzureClientSecret, lookupEnvOrString(keyAzureClientSecretEnvironment, ""), "The Azure Client Secret.")
-	flag.StringVar(&c.Azure.TenantId, keyAzureTenantId, lookupEnvOrString(keyAzureTenantIdEnvironment, ""), "The Azure Tenant ID.")
-	flag.StringVar(&c.Azure.Group, keyAzureGroup, lookupEnvOrString(keyAzureGroupEnvironment, ""), "The Azure Group.")
-	flag.BoolVar(&c.DryRun, keyDryRun, lookupEnvOrBool(keyDryRunEnvironment, false), "Dry run mode.")
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	var inviteOrganizations, inviteTeamIds string
+	var protectedLogins, protectedEmails, protectedPatterns string
+	var azureGroups, azureExcludeGroups string
+	var matchStrategies string
+	var reports string
+	var orgMappings string
+	var teamMappings string
+	var appPrivateKeyFile string
+	// envErrs collects the environment variables with invalid values, the flags fall back to their defaults
+	var envErrs []error
+	fs.StringVar(&c.GitHub.Token, keyGithubToken, lookupEnvOrString(keyGitHubTokenEnvironment, ""), "The GitHub Token to use for authentication.")
+	fs.StringVar(&c.GitHub.Enterprise, keyGithubEnterprise, lookupEnvOrString(keyGitHubEnterpriseEnvironment, ""), "The GitHub Enterprise to query for repositories.")
+	fs.StringVar(&c.Azure.ClientId, keyAzureClientId, lookupEnvOrString(keyAzureClientIdEnvironment, ""), "The Azure Client ID.")
+	fs.StringVar(&c.Azure.ClientSecret, keyAzureClientSecret, lookupEnvOrString(keyAzureClientSecretEnvironment, ""), "The Azure Client Secret.")
+	fs.StringVar(&c.Azure.TenantId, keyAzureTenantId, lookupEnvOrString(keyAzureTenantIdEnvironment, ""), "The Azure Tenant ID.")
+	fs.StringVar(&azureGroups, keyAzureGroup, lookupEnvOrString(keyAzureGroupEnvironment, ""), "Comma separated list of Azure Groups whose members are synced.")
+	fs.StringVar(&azureExcludeGroups, keyAzureExcludeGroup, lookupEnvOrString(keyAzureExcludeGroupEnvironment, ""), "Comma separated list of Azure Groups whose members are never synced.")
+	fs.BoolVar(&c.Azure.Transitive, keyAzureTransitive, lookupEnvOrBool(keyAzureTransitiveEnvironment, false, &envErrs), "Include the members of nested Azure Groups.")
+	fs.IntVar(&c.Azure.MaxRetries, keyAzureMaxRetries, lookupEnvOrInt(keyAzureMaxRetriesEnvironment, 5, &envErrs), "Retries of Graph requests that are throttled or fail transiently.")
+	fs.StringVar(&c.Azure.Auth, keyAzureAuth, lookupEnvOrString(keyAzureAuthEnvironment, "secret"), "The Azure authentication (secret, certificate, managed-identity, cli, oidc).")
+	fs.StringVar(&c.Azure.CertificateFile, keyAzureCertificate, lookupEnvOrString(keyAzureCertificateEnvironment, ""), "The PEM or PKCS#12 file with the Azure client certificate and private key.")
+	fs.BoolVar(&c.DryRun, keyDryRun, lookupEnvOrBool(keyDryRunEnvironment, false, &envErrs), "Dry run mode.")
+	fs.StringVar(&inviteOrganizations, keyGithubInviteOrganizations, lookupEnvOrString(keyGithubInviteOrganizationsEnvironment, ""), "Comma separated list of organizations to invite new users into.")
+	fs.StringVar(&c.GitHub.InviteRole, keyGithubInviteRole, lookupEnvOrString(keyGithubInviteRoleEnvironment, "direct_member"), "The role of invited users (direct_member, admin, billing_manager).")
+	fs.StringVar(&inviteTeamIds, keyGithubInviteTeamIds, lookupEnvOrString(keyGithubInviteTeamIdsEnvironment, ""), "Comma separated list of team IDs to add invited users to.")
+	fs.IntVar(&c.GitHub.MaxRetries, keyGithubMaxRetries, lookupEnvOrInt(keyGithubMaxRetriesEnvironment, 5, &envErrs), "Retries of GitHub requests that are rate limited or fail transiently.")
+	fs.Int64Var(&c.GitHub.AppId, keyGithubAppId, int64(lookupEnvOrInt(keyGithubAppIdEnvironment, 0, &envErrs)), "The ID of the GitHub App to authenticate as instead of the token.")
+	fs.StringVar(&appPrivateKeyFile, keyGithubAppPrivateKeyFile, lookupEnvOrString(keyGithubAppPrivateKeyFileEnvironment, ""), "The PEM file with the private key of the GitHub App, the key can also be passed in GITHUB_APP_PRIVATE_KEY.")
+	fs.Int64Var(&c.GitHub.AppInstallationId, keyGithubAppInstallationId, int64(lookupEnvOrInt(keyGithubAppInstallationIdEnvironment, 0, &envErrs)), "The installation ID of the GitHub App, 0 looks up the installation on the enterprise.")
+	fs.StringVar(&c.GitHub.Host, keyGithubHost, lookupEnvOrString(keyGithubHostEnvironment, "github.com"), "The GitHub host, github.com, a GHE.com tenant or a GitHub Enterprise Server.")
+	fs.StringVar(&c.GitHub.ApiUrl, keyGithubApiUrl, lookupEnvOrString(keyGithubApiUrlEnvironment, ""), "The GitHub REST API URL, derived from the host if empty.")
+	fs.StringVar(&c.GitHub.GraphqlUrl, keyGithubGraphqlUrl, lookupEnvOrString(keyGithubGraphqlUrlEnvironment, ""), "The GitHub GraphQL API URL, derived from the host if empty.")
+	fs.StringVar(&c.GitHub.CaBundle, keyGithubCaBundle, lookupEnvOrString(keyGithubCaBundleEnvironment, ""), "A PEM file with additional CA certificates trusted for GitHub.")
+	fs.StringVar(&c.GitHub.Mode, keyGithubMode, lookupEnvOrString(keyGithubModeEnvironment, ModeInvite), "How users are added, invite for personal accounts or emu to provision Enterprise Managed Users with SCIM.")
+
+	fs.StringVar(&c.PlanFile, keyPlanFile, lookupEnvOrString(keyPlanFileEnvironment, "plan.json"), "The plan file written by plan and read by apply.")
+	fs.DurationVar(&c.State.GracePeriod, keyGracePeriod, lookupEnvOrDuration(keyGracePeriodEnvironment, 0, &envErrs), "Delay the deletion of users missing in Azure, e.g. 72h, 0 deletes immediately.")
+	fs.StringVar(&c.State.File, keyStateFile, lookupEnvOrString(keyStateFileEnvironment, "state.json"), "The state file with the pending deletions, the path in the state repository if set.")
+	fs.StringVar(&c.State.Repository, keyStateRepository, lookupEnvOrString(keyStateRepositoryEnvironment, ""), "Store the state file in this repository (owner/name) instead of locally.")
+	fs.StringVar(&c.State.Branch, keyStateBranch, lookupEnvOrString(keyStateBranchEnvironment, ""), "The branch of the state repository, the default branch if empty.")
+	fs.StringVar(&c.SnapshotDir, keySnapshotDir, lookupEnvOrString(keySnapshotDirEnvironment, ""), "Write a snapshot of the organizations, teams and repositories of every deleted user into this directory.")
+	fs.StringVar(&c.RestoreFile, keyRestoreFile, lookupEnvOrString(keyRestoreFileEnvironment, ""), "The snapshot file read by restore.")
+	fs.IntVar(&c.GuardRails.MaxDeleteCount, keyMaxDeleteCount, lookupEnvOrInt(keyMaxDeleteCountEnvironment, 20, &envErrs), "Abort if more users would be deleted, 0 disables the check.")
+	fs.IntVar(&c.GuardRails.MaxDeletePercent, keyMaxDeletePercent, lookupEnvOrInt(keyMaxDeletePercentEnvironment, 10, &envErrs), "Abort if a higher percentage of members would be deleted, 0 disables the check.")
+	fs.IntVar(&c.GuardRails.MaxUnmatchable, keyMaxUnmatchable, lookupEnvOrInt(keyMaxUnmatchableEnvironment, 0, &envErrs), "Abort if more Azure users can not be matched or invited, 0 disables the check.")
+	fs.BoolVar(&c.GuardRails.AllowMassDelete, keyAllowMassDelete, lookupEnvOrBool(keyAllowMassDeleteEnvironment, false, &envErrs), "Disable the deletion guard rails for intentional large cleanups.")
+	fs.StringVar(&protectedLogins, keyProtectedLogins, lookupEnvOrString(keyProtectedLoginsEnvironment, ""), "Comma separated list of GitHub logins that are never deleted.")
+	fs.StringVar(&protectedEmails, keyProtectedEmails, lookupEnvOrString(keyProtectedEmailsEnvironment, ""), "Comma separated list of emails that are never deleted.")
+	fs.StringVar(&protectedPatterns, keyProtectedPatterns, lookupEnvOrString(keyProtectedPatternsEnvironment, ""), "Whitespace separated list of regular expressions matching logins or emails that are never deleted.")
+	fs.BoolVar(&c.Protection.Owners, keyProtectOwners, lookupEnvOrBool(keyProtectOwnersEnvironment, false, &envErrs), "Never delete enterprise owners.")
+	fs.BoolVar(&c.Policy.RemoveDisabled, keyRemoveDisabled, lookupEnvOrBool(keyRemoveDisabledEnvironment, false, &envErrs), "Remove users whose Azure account is disabled.")
+	fs.BoolVar(&c.Policy.RemoveGuests, keyRemoveGuests, lookupEnvOrBool(keyRemoveGuestsEnvironment, false, &envErrs), "Remove users that are guests in Azure.")
+	fs.IntVar(&c.Policy.InactiveDays, keyInactiveDays, lookupEnvOrInt(keyInactiveDaysEnvironment, 0, &envErrs), "Report users without contribution or login in this many days (at most 365), 0 disables the check.")
+	fs.BoolVar(&c.Policy.RemoveInactive, keyRemoveInactive, lookupEnvOrBool(keyRemoveInactiveEnvironment, false, &envErrs), "Remove inactive users instead of only reporting them, they are not invited again.")
+	fs.StringVar(&matchStrategies, keyMatchStrategies, lookupEnvOrString(keyMatchStrategiesEnvironment, "mail"), "Comma separated list of strategies to match the SAML NameID with Azure users, tried in order (mail, upn, addresses, employeeId, objectId).")
+	fs.StringVar(&reports, keyReport, lookupEnvOrString(keyReportEnvironment, ""), "Comma separated list of reports to write as format:path, the format is json, csv or markdown.")
+	fs.IntVar(&c.Verbose, keyVerbose, lookupEnvOrInt(keyVerboseEnvironment, 2, &envErrs), "Verbosity, 0=error, 1=warn, 2=info, 3=debug.")
+	fs.StringVar(&c.LogFormat, keyLogFormat, lookupEnvOrString(keyLogFormatEnvironment, "text"), "The log format, text, json or actions.")
+	fs.BoolVar(&c.FailFast, keyFailFast, lookupEnvOrBool(keyFailFastEnvironment, false, &envErrs), "Stop after the first failed invitation or removal.")
+	fs.StringVar(&orgMappings, keyOrgMappings, lookupEnvOrString(keyOrgMappingsEnvironment, ""), "Comma separated list of group:organization:role, the members of the Azure group get the role (member, admin) in the organization.")
+	fs.StringVar(&teamMappings, keyTeamMappings, lookupEnvOrString(keyTeamMappingsEnvironment, ""), "Comma separated list of group:organization/team:role, the members of the Azure group get the role (member, maintainer) in the team.")
+	fs.BoolVar(&c.CreateTeams, keyCreateTeams, lookupEnvOrBool(keyCreateTeamsEnvironment, false, &envErrs), "Create mapped teams that do not exist.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] [sync|plan|apply|restore]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if len(envErrs) > 0 {
+		err := errors.Join(envErrs...)
+		slog.Error("Invalid environment variable", "error", err)
+		return nil, err
+	}
+
+	c.Azure.Groups = splitList(azureGroups)
+	c.Azure.ExcludeGroups = splitList(azureExcludeGroups)
+	c.Protection.Logins = splitList(protectedLogins)
+	c.Protection.Emails = splitList(protectedEmails)
+	for _, pattern := range strings.Fields(protectedPatterns) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			slog.Error("Invalid protected pattern", "pattern", pattern, "error", err)
+			return nil, fmt.Errorf("invalid protected pattern %s: %w", pattern, err)
+		}
+		c.Protection.Patterns = append(c.Protection.Patterns, re)
+	}
 
-	flag.Parse()
+	c.MatchStrategies = splitList(matchStrategies)
+	for _, report := range splitList(reports) {
+		format, path, ok := strings.Cut(report, ":")
+		if !ok || path == "" {
+			slog.Error("Invalid report, expected format:path", "report", report)
+			return nil, fmt.Errorf("invalid report %s, expected format:path", report)
+		}
+		switch format {
+		case "json", "csv", "markdown":
+		default:
+			slog.Error("Invalid report format", "format", format)
+			return nil, fmt.Errorf("invalid report format %s", format)
+		}
+		c.Reports = append(c.Reports, Report{Format: format, Path: path})
+	}
 
-	if c.GitHub.Token == "" {
-		slog.Error("GitHub Token is required")
-		return nil, errors.New("GitHub Token is required")
+	for _, mapping := range splitList(orgMappings) {
+		parts := strings.Split(mapping, ":")
+		if len(parts) == 2 {
+			parts = append(parts, "member")
+		}
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			slog.Error("Invalid organization mapping, expected group:organization:role", "mapping", mapping)
+			return nil, fmt.Errorf("invalid organization mapping %s, expected group:organization:role", mapping)
+		}
+		switch parts[2] {
+		case "member", "admin":
+		default:
+			slog.Error("Invalid organization role", "role", parts[2])
+			return nil, fmt.Errorf("invalid organization role %s", parts[2])
+		}
+		c.OrgMappings = append(c.OrgMappings, OrgMapping{Group: parts[0], Organization: parts[1], Role: parts[2]})
+	}
+
+	for _, mapping := range splitList(teamMappings) {
+		parts := strings.Split(mapping, ":")
+		if len(parts) == 2 {
+			parts = append(parts, "member")
+		}
+		org, team, ok := "", "", len(parts) == 3
+		if ok {
+			org, team, ok = strings.Cut(parts[1], "/")
+		}
+		if !ok || parts[0] == "" || org == "" || team == "" {
+			slog.Error("Invalid team mapping, expected group:organization/team:role", "mapping", mapping)
+			return nil, fmt.Errorf("invalid team mapping %s, expected group:organization/team:role", mapping)
+		}
+		switch parts[2] {
+		case "member", "maintainer":
+		default:
+			slog.Error("Invalid team role", "role", parts[2])
+			return nil, fmt.Errorf("invalid team role %s", parts[2])
+		}
+		c.TeamMappings = append(c.TeamMappings, TeamMapping{Group: parts[0], Organization: org, Team: team, Role: parts[2]})
+	}
+
+	c.Command = lookupEnvOrString(keyCommandEnvironment, CommandSync)
+	if fs.NArg() > 0 {
+		c.Command = fs.Arg(0)
+	}
+	switch c.Command {
+	case CommandSync, CommandPlan, CommandApply:
+	case CommandRestore:
+		if c.RestoreFile == "" {
+			slog.Error("Restore file is required")
+			return nil, errors.New("restore file is required")
+		}
+	default:
+		slog.Error("Invalid command", "command", c.Command)
+		return nil, fmt.Errorf("invalid command %s", c.Command)
+	}
+
+	c.GitHub.InviteOrganizations = splitList(inviteOrganizations)
+	for _, id := range splitList(inviteTeamIds) {
+		teamId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			slog.Error("Invalid team ID", "teamId", id, "error", err)
+			return nil, fmt.Errorf("invalid team ID %s: %w", id, err)
+		}
+		c.GitHub.InviteTeamIds = append(c.GitHub.InviteTeamIds, teamId)
+	}
+
+	if c.Verbose < 0 || c.Verbose > 3 {
+		slog.Error("Verbosity must be between 0 and 3", "verbose", c.Verbose)
+		return nil, errors.New("verbosity must be between 0 and 3")
+	}
+	switch c.LogFormat {
+	case "text", "json", "actions":
+	default:
+		slog.Error("Invalid log format", "format", c.LogFormat)
+		return nil, fmt.Errorf("invalid log format %s", c.LogFormat)
+	}
+	c.GitHub.AppPrivateKey = lookupEnvOrString(keyGithubAppPrivateKeyEnvironment, "")
+	if appPrivateKeyFile != "" {
+		key, err := os.ReadFile(appPrivateKeyFile)
+		if err != nil {
+			slog.Error("Unable to read GitHub App private key", "file", appPrivateKeyFile, "error", err)
+			return nil, fmt.Errorf("unable to read GitHub App private key %s: %w", appPrivateKeyFile, err)
+		}
+		c.GitHub.AppPrivateKey = string(key)
+	}
+	if c.GitHub.AppId != 0 {
+		if c.GitHub.AppPrivateKey == "" {
+			slog.Error("GitHub App private key is required")
+			return nil, errors.New("GitHub App private key is required")
+		}
+	} else if c.GitHub.Token == "" {
+		slog.Error("GitHub Token or GitHub App is required")
+		return nil, errors.New("GitHub Token or GitHub App is required")
 	}
 	if c.GitHub.Enterprise == "" {
 		slog.Error("GitHub Enterprise is required")
 		return nil, errors.New("GitHub Enterprise is required")
 	}
-	if c.Azure.ClientId == "" {
-		slog.Error("Azure Client ID is required")
-		return nil, errors.New("Azure Client ID is required")
+	switch c.GitHub.Mode {
+	case ModeInvite, ModeEMU:
+	default:
+		slog.Error("Invalid GitHub mode", "mode", c.GitHub.Mode)
+		return nil, fmt.Errorf("invalid GitHub mode %s", c.GitHub.Mode)
+	}
+	if c.GitHub.Mode == ModeEMU && len(c.OrgMappings) > 0 {
+		slog.Error("Organization mappings are not supported for Enterprise Managed Users, use SCIM groups")
+		return nil, errors.New("organization mappings are not supported for Enterprise Managed Users")
+	}
+	if c.GitHub.Mode == ModeEMU && len(c.TeamMappings) > 0 {
+		slog.Error("Team mappings are not supported for Enterprise Managed Users, use SCIM groups")
+		return nil, errors.New("team mappings are not supported for Enterprise Managed Users")
+	}
+	if c.Policy.InactiveDays < 0 || c.Policy.InactiveDays > 365 {
+		slog.Error("Inactive days must be between 0 and 365", "days", c.Policy.InactiveDays)
+		return nil, errors.New("inactive days must be between 0 and 365")
+	}
+	if c.Policy.RemoveInactive && c.Policy.InactiveDays == 0 {
+		slog.Error("Inactive days are required to remove inactive users")
+		return nil, errors.New("inactive days are required to remove inactive users")
 	}
-	if c.Azure.ClientSecret == "" {
-		slog.Error("Azure Client Secret is required")
-		return nil, errors.New("Azure Client Secret is required")
+	if c.GitHub.Mode == ModeEMU && c.Policy.InactiveDays > 0 {
+		slog.Error("Inactive users are not supported for Enterprise Managed Users")
+		return nil, errors.New("inactive users are not supported for Enterprise Managed Users")
 	}
-	if c.Azure.TenantId == "" {
-		slog.Error("Azure Tenant ID is required")
-		return nil, errors.New("Azure Tenant ID is required")
+	if c.GitHub.Mode == ModeEMU && (c.SnapshotDir != "" || c.Command == CommandRestore) {
+		slog.Error("Snapshots are not supported for Enterprise Managed Users")
+		return nil, errors.New("snapshots are not supported for Enterprise Managed Users")
 	}
-	if c.Azure.Group == "" {
-		slog.Error("Azure Group is required")
-		return nil, errors.New("Azure Group is required")
+	switch c.GitHub.InviteRole {
+	case "direct_member", "admin", "billing_manager":
+	default:
+		slog.Error("Invalid invite role", "role", c.GitHub.InviteRole)
+		return nil, fmt.Errorf("invalid invite role %s", c.GitHub.InviteRole)
+	}
+	if c.GuardRails.MaxDeleteCount < 0 {
+		slog.Error("Maximum delete count must not be negative", "count", c.GuardRails.MaxDeleteCount)
+		return nil, errors.New("maximum delete count must not be negative")
+	}
+	if c.GuardRails.MaxUnmatchable < 0 {
+		slog.Error("Maximum unmatchable count must not be negative", "count", c.GuardRails.MaxUnmatchable)
+		return nil, errors.New("maximum unmatchable count must not be negative")
+	}
+	if c.GuardRails.MaxDeletePercent < 0 || c.GuardRails.MaxDeletePercent > 100 {
+		slog.Error("Maximum delete percent must be between 0 and 100", "percent", c.GuardRails.MaxDeletePercent)
+		return nil, errors.New("maximum delete percent must be between 0 and 100")
+	}
+	c.Azure.CertificatePassword = lookupEnvOrString(keyAzureCertificatePasswordEnvironment, "")
+	// restore only recreates the memberships of a snapshot on GitHub and does not read Azure
+	if c.Command != CommandRestore {
+		switch c.Azure.Auth {
+		case "secret", "certificate", "oidc":
+			if c.Azure.ClientId == "" {
+				slog.Error("Azure Client ID is required")
+				return nil, errors.New("Azure Client ID is required")
+			}
+			if c.Azure.TenantId == "" {
+				slog.Error("Azure Tenant ID is required")
+				return nil, errors.New("Azure Tenant ID is required")
+			}
+		case "managed-identity", "cli":
+		default:
+			slog.Error("Invalid Azure authentication", "auth", c.Azure.Auth)
+			return nil, fmt.Errorf("invalid Azure authentication %s", c.Azure.Auth)
+		}
+		if c.Azure.Auth == "secret" && c.Azure.ClientSecret == "" {
+			slog.Error("Azure Client Secret is required")
+			return nil, errors.New("Azure Client Secret is required")
+		}
+		if c.Azure.Auth == "certificate" && c.Azure.CertificateFile == "" {
+			slog.Error("Azure Client Certificate is required")
+			return nil, errors.New("Azure Client Certificate is required")
+		}
+		if len(c.Azure.Groups) == 0 {
+			slog.Error("Azure Group is required")
+			return nil, errors.New("Azure Group is required")
+		}
+	}
+	if c.State.GracePeriod < 0 {
+		slog.Error("Invalid grace period", "gracePeriod", c.State.GracePeriod)
+		return nil, fmt.Errorf("invalid grace period %s", c.State.GracePeriod)
+	}
+	if c.State.GracePeriod > 0 && c.State.File == "" {
+		slog.Error("State file is required for the grace period")
+		return nil, errors.New("state file is required for the grace period")
 	}
 
 	return &c, nil
@@ -92,24 +560,53 @@ func lookupEnvOrString(key string, defaultVal string) string {
 	return defaultVal
 }
 
-func lookupEnvOrInt(key string, defaultVal int) int {
+// lookupEnvOrInt returns the integer in the environment variable, an invalid value is appended to errs
+func lookupEnvOrInt(key string, defaultVal int, errs *[]error) int {
 	if val, ok := os.LookupEnv(key); ok {
 		v, err := strconv.Atoi(val)
 		if err != nil {
-			log.Fatalf("LookupEnvOrInt[%s]: %v", key, err)
+			*errs = append(*errs, fmt.Errorf("invalid %s: %w", key, err))
+			return defaultVal
+		}
+		return v
+	}
+	return defaultVal
+}
+
+// lookupEnvOrDuration returns the duration in the environment variable, an invalid value is appended to errs
+func lookupEnvOrDuration(key string, defaultVal time.Duration, errs *[]error) time.Duration {
+	if val, ok := os.LookupEnv(key); ok {
+		v, err := time.ParseDuration(val)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("invalid %s: %w", key, err))
+			return defaultVal
 		}
 		return v
 	}
 	return defaultVal
 }
 
-func lookupEnvOrBool(key string, defaultVal bool) bool {
+// lookupEnvOrBool returns the boolean in the environment variable, an invalid value is appended to errs
+func lookupEnvOrBool(key string, defaultVal bool, errs *[]error) bool {
 	if val, ok := os.LookupEnv(key); ok {
 		v, err := strconv.ParseBool(val)
 		if err != nil {
-			log.Fatalf("LookupEnvOrBool[%s]: %v", key, err)
+			*errs = append(*errs, fmt.Errorf("invalid %s: %w", key, err))
+			return defaultVal
 		}
 		return v
 	}
 	return defaultVal
 }
+
+// splitList splits a comma separated list and drops empty entries
+func splitList(val string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"slices"
+	"testing"
+)
+
+// required are the arguments every configuration needs
+var required = []string{"-github-token", "token", "-github-enterprise", "acme",
+	"-azure-client-id", "client", "-azure-tenant-id", "tenant", "-azure-client-secret", "secret", "-azure-group", "developers"}
+
+func TestParse(t *testing.T) {
+	c, err := parse(append(slices.Clone(required), "-org-mappings", "admins:acme:admin,developers:acme",
+		"-team-mappings", "developers:acme/backend", "-report", "json:report.json", "plan"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if c.Command != CommandPlan {
+		t.Errorf("command = %s, want %s", c.Command, CommandPlan)
+	}
+	want := []OrgMapping{{Group: "admins", Organization: "acme", Role: "admin"}, {Group: "developers", Organization: "acme", Role: "member"}}
+	if !slices.Equal(c.OrgMappings, want) {
+		t.Errorf("organization mappings = %+v, want %+v", c.OrgMappings, want)
+	}
+	if len(c.TeamMappings) != 1 || c.TeamMappings[0] != (TeamMapping{Group: "developers", Organization: "acme", Team: "backend", Role: "member"}) {
+		t.Errorf("team mappings = %+v, want developers in acme/backend", c.TeamMappings)
+	}
+	if len(c.Reports) != 1 || c.Reports[0] != (Report{Format: "json", Path: "report.json"}) {
+		t.Errorf("reports = %+v, want json:report.json", c.Reports)
+	}
+	if c.Policy.RemoveDisabled {
+		t.Error("remove disabled is on by default, want opt-in")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "invalid flag value", args: []string{"-max-delete-count", "abc"}},
+		{name: "unknown flag", args: []string{"-unknown"}},
+		{name: "invalid command", args: []string{"deploy"}},
+		{name: "invalid organization role", args: []string{"-org-mappings", "admins:acme:owner"}},
+		{name: "invalid team mapping", args: []string{"-team-mappings", "developers:backend"}},
+		{name: "invalid report format", args: []string{"-report", "xml:report.xml"}},
+		{name: "invalid protected pattern", args: []string{"-protected-patterns", "("}},
+		{name: "restore without file", args: []string{"restore"}},
+		{name: "remove inactive without days", args: []string{"-remove-inactive"}},
+		{name: "delete percent above 100", args: []string{"-max-delete-percent", "101"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// flags precede the command
+			args := append(slices.Clone(required), tt.args...)
+			if _, err := parse(args); err == nil {
+				t.Errorf("parse(%v) succeeded, want an error", tt.args)
+			}
+		})
+	}
+}
+
+func TestParseRestoreWithoutAzure(t *testing.T) {
+	c, err := parse([]string{"-github-token", "token", "-github-enterprise", "acme", "-restore-file", "snapshot.json", "restore"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if c.Command != CommandRestore {
+		t.Errorf("command = %s, want %s", c.Command, CommandRestore)
+	}
+}
+
+func TestParseHelp(t *testing.T) {
+	if _, err := parse([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("error = %v, want flag.ErrHelp", err)
+	}
+}
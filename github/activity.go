@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"github.com/google/go-github/v61/github"
+	"github.com/shurcooL/githubv4"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type contributionWeek struct {
+	ContributionDays []struct {
+		Date              string
+		ContributionCount int
+	}
+}
+
+// lastContribution returns the last day of the calendar with contributions, zero if there is none
+func lastContribution(weeks []contributionWeek) time.Time {
+	last := time.Time{}
+	for _, week := range weeks {
+		for _, day := range week.ContributionDays {
+			if day.ContributionCount == 0 {
+				continue
+			}
+			date, err := time.Parse(time.DateOnly, day.Date)
+			if err == nil && date.After(last) {
+				last = date
+			}
+		}
+	}
+	return last
+}
+
+// lastActivity returns the later of the last contribution and the last login, nil if there was neither
+func (u GitHubUser) lastActivity() *time.Time {
+	last := u.LastContribution
+	if u.LastLogin.After(last) {
+		last = u.LastLogin
+	}
+	if last.IsZero() {
+		return nil
+	}
+	return &last
+}
+
+// lastLogins returns the time of the last login since the time of the logins from the enterprise audit log
+// by lower case login. Only the logins are looked up, one request each for their most recent login event,
+// instead of paging through the logins of the whole enterprise.
+func (g *GitHub) lastLogins(ctx context.Context, since time.Time, logins []string) (map[string]time.Time, error) {
+	slog.InfoContext(ctx, "Loading logins from audit log", "enterprise", g.config.Enterprise, "since", since.Format(time.DateOnly), "users", len(logins))
+	last := map[string]time.Time{}
+
+	for _, login := range logins {
+		options := &github.GetAuditLogOptions{
+			Phrase:            github.String("action:user.login actor:" + login + " created:>=" + since.Format(time.DateOnly)),
+			Order:             github.String("desc"),
+			ListCursorOptions: github.ListCursorOptions{PerPage: 1},
+		}
+		entries, _, err := g.client.Enterprise.GetAuditLog(ctx, g.config.Enterprise, options)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to load audit log", "login", login, "error", err)
+			return nil, err
+		}
+		for _, e := range entries {
+			if strings.EqualFold(e.GetActor(), login) {
+				last[strings.ToLower(login)] = e.GetTimestamp().Time
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "Loaded logins from audit log", "users", len(last))
+	return last, nil
+}
+
+// joinDates returns when the enterprise accounts of the members were created by lower case login
+func (g *GitHub) joinDates(ctx context.Context) (map[string]time.Time, error) {
+	joined := map[string]time.Time{}
+
+	var query struct {
+		Enterprise struct {
+			Members struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   githubv4.String
+				}
+				Nodes []struct {
+					EnterpriseUserAccount struct {
+						Login     string
+						CreatedAt time.Time
+					} `graphql:"... on EnterpriseUserAccount"`
+				}
+			} `graphql:"members(first: $first, after: $after)"`
+		} `graphql:"enterprise(slug: $slug)"`
+	}
+
+	variables := map[string]interface{}{
+		"slug":  githubv4.String(g.config.Enterprise),
+		"first": githubv4.Int(100),
+		"after": (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query join dates", "error", err)
+			return nil, err
+		}
+
+		for _, n := range query.Enterprise.Members.Nodes {
+			if n.EnterpriseUserAccount.Login != "" {
+				joined[strings.ToLower(n.EnterpriseUserAccount.Login)] = n.EnterpriseUserAccount.CreatedAt
+			}
+		}
+
+		if !query.Enterprise.Members.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["after"] = githubv4.NewString(query.Enterprise.Members.PageInfo.EndCursor)
+	}
+
+	slog.DebugContext(ctx, "Loaded join dates", "users", len(joined))
+	return joined, nil
+}
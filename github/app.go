@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v61/github"
+	"golang.org/x/oauth2"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtLifetime is below the maximum of 10 minutes accepted by GitHub
+	jwtLifetime = 9 * time.Minute
+	// jwtClockSkew backdates the JWT to tolerate clock drift
+	jwtClockSkew = 60 * time.Second
+)
+
+// appJWTSource issues the JWTs that authenticate as the GitHub App itself
+type appJWTSource struct {
+	appId int64
+	key   any
+}
+
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(jwtLifetime)
+	claims := jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(s.appId, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-jwtClockSkew)),
+		ExpiresAt: jwt.NewNumericDate(expiry),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("error signing GitHub App JWT: %w", err)
+	}
+	return &oauth2.Token{AccessToken: signed, TokenType: "Bearer", Expiry: expiry}, nil
+}
+
+// installationTokenSource issues installation access tokens, which expire after an hour.
+// Wrapped in oauth2.ReuseTokenSource a new token is created shortly before the old one expires.
+// Token has no context, ctx is the context of the caller the source was created for, like in oauth2.NewClient.
+type installationTokenSource struct {
+	ctx            context.Context
+	app            *github.Client
+	installationId int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.app.Apps.CreateInstallationToken(s.ctx, s.installationId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating installation token for installation %d: %w", s.installationId, err)
+	}
+	slog.Debug("Created GitHub App installation token",
+		"installationId", s.installationId,
+		"expiresAt", token.GetExpiresAt().Time)
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+// appTokenSource returns a token source for the installation of the GitHub App. Without an
+// installation ID the installation on the enterprise is looked up.
+func appTokenSource(ctx context.Context, config Config, endpoints endpoints, base http.RoundTripper) (oauth2.TokenSource, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(config.AppPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: error parsing GitHub App private key: %w", ErrInvalidConfig, err)
+	}
+
+	app, err := newRESTClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, &appJWTSource{appId: config.AppId, key: key}),
+			Base:   base,
+		},
+	}, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	installationId := config.AppInstallationId
+	if installationId == 0 {
+		installationId, err = findInstallation(ctx, app, endpoints.web, config.Enterprise)
+		if err != nil {
+			return nil, err
+		}
+	}
+	slog.Info("Using GitHub App", "appId", config.AppId, "installationId", installationId)
+
+	return oauth2.ReuseTokenSource(nil, &installationTokenSource{
+		ctx:            ctx,
+		app:            app,
+		installationId: installationId,
+	}), nil
+}
+
+// findInstallation returns the ID of the installation of the GitHub App on the enterprise
+func findInstallation(ctx context.Context, app *github.Client, web string, enterprise string) (int64, error) {
+	options := &github.ListOptions{PerPage: 100}
+	for {
+		installations, resp, err := app.Apps.ListInstallations(ctx, options)
+		if err != nil {
+			return 0, fmt.Errorf("error listing GitHub App installations: %w", err)
+		}
+		for _, installation := range installations {
+			if !strings.EqualFold(installation.GetTargetType(), "Enterprise") {
+				continue
+			}
+			// enterprise accounts have a slug instead of a login, it is the last part of the URL
+			account := installation.GetAccount()
+			if strings.EqualFold(account.GetLogin(), enterprise) ||
+				strings.EqualFold(account.GetHTMLURL(), web+"/enterprises/"+enterprise) {
+				return installation.GetID(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	return 0, fmt.Errorf("GitHub App is not installed on enterprise %s", enterprise)
+}
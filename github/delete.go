@@ -2,11 +2,17 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"github.com/prodyna/sync-enterprise/sync"
 	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 	"log/slog"
 )
 
+// Remove removes the member from the enterprise
+func (g *GitHub) Remove(ctx context.Context, member sync.Member) error {
+	return g.DeleteUser(ctx, member.ID)
+}
+
 func (g GitHub) DeleteUser(ctx context.Context, userId string) error {
 	enterpriseId := g.enterpriseId
 	slog.InfoContext(ctx, "Deleting user", "userId", userId, "enterprise", g.config.Enterprise, "enterpriseId", g.enterpriseId)
@@ -31,16 +37,10 @@ func (g GitHub) DeleteUser(ctx context.Context, userId string) error {
 		UserID:       githubv4.ID(userId),
 	}
 
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: g.config.Token},
-	)
-	httpClient := oauth2.NewClient(ctx, src)
-	client := githubv4.NewClient(httpClient)
-
-	err := client.Mutate(ctx, &mutation, input, nil)
+	err := g.v4client.Mutate(ctx, &mutation, input, nil)
 	if err != nil {
 		slog.Warn("Unable to delete user", "userId", userId, "enterprise", g.config.Enterprise, "error", err)
-		return nil
+		return fmt.Errorf("error removing enterprise member %s: %w", userId, err)
 	}
 	slog.InfoContext(ctx, "User deleted", "userId", userId, "enterprise", g.config.Enterprise)
 
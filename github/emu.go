@@ -0,0 +1,372 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"github.com/prodyna/sync-enterprise/sync"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// EMU is the target for Enterprise Managed Users. Instead of invitations the users are
+// provisioned, updated and deactivated with the SCIM API of the enterprise.
+type EMU struct {
+	*GitHub
+	// users are all SCIM users of the enterprise, including deactivated ones
+	users []scimUser
+	// groups are all SCIM groups of the enterprise, created groups are added
+	groups []scimGroup
+}
+
+func NewEMU(ctx context.Context, config Config) (*EMU, error) {
+	gh, err := New(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &EMU{GitHub: gh}, nil
+}
+
+// scimUsers returns all SCIM users of the enterprise
+func (e *EMU) scimUsers(ctx context.Context) ([]scimUser, error) {
+	if e.users == nil {
+		slog.InfoContext(ctx, "Loading SCIM users", "enterprise", e.config.Enterprise)
+		users, err := scimListAll[scimUser](ctx, e.GitHub, "Users")
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to load SCIM users", "error", err)
+			return nil, err
+		}
+		e.users = users
+		slog.InfoContext(ctx, "Loaded SCIM users", "users", len(e.users))
+	}
+	return e.users, nil
+}
+
+// Members returns the active SCIM users
+func (e *EMU) Members(ctx context.Context) ([]sync.Member, error) {
+	users, err := e.scimUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []sync.Member{}
+	for _, u := range users {
+		if !u.Active {
+			continue
+		}
+		members = append(members, sync.Member{
+			ID:           u.ID,
+			Login:        u.UserName,
+			Email:        u.primaryEmail(),
+			ScimUsername: u.UserName,
+			DisplayName:  u.DisplayName,
+		})
+	}
+	return members, nil
+}
+
+// find returns the SCIM user of the identity, matched by external ID or user name
+func (e *EMU) find(identity sync.Identity) (scimUser, bool) {
+	userName := scimUserName(identity)
+	for _, u := range e.users {
+		if identity.ObjectId != "" && u.ExternalID == identity.ObjectId {
+			return u, true
+		}
+		if strings.EqualFold(u.UserName, userName) {
+			return u, true
+		}
+	}
+	return scimUser{}, false
+}
+
+// Invite provisions the identity, a deactivated SCIM user of the identity is reactivated
+func (e *EMU) Invite(ctx context.Context, identity sync.Identity) ([]sync.InviteResult, error) {
+	_, err := e.scimUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := sync.InviteResult{
+		Organization: e.config.Enterprise,
+		Email:        identity.Email,
+		Status:       sync.InviteSent,
+	}
+
+	user := newScimUser(identity)
+	existing, ok := e.find(identity)
+	if ok {
+		slog.InfoContext(ctx, "Reactivating SCIM user", "userName", existing.UserName, "id", existing.ID)
+		err = e.scimDo(ctx, http.MethodPut, e.scimPath("Users/"+existing.ID), user, &user)
+	} else {
+		slog.InfoContext(ctx, "Provisioning SCIM user", "userName", user.UserName, "email", identity.Email)
+		err = e.scimDo(ctx, http.MethodPost, e.scimPath("Users"), user, &user)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Unable to provision user", "userName", user.UserName, "error", err)
+		result.Status = sync.InviteFailed
+		result.Reason = err.Error()
+		return []sync.InviteResult{result}, nil
+	}
+
+	e.store(user)
+	return []sync.InviteResult{result}, nil
+}
+
+// Update replaces the name and emails of the SCIM user with those of the identity
+func (e *EMU) Update(ctx context.Context, member sync.Member, identity sync.Identity) error {
+	user := newScimUser(identity)
+	err := e.scimDo(ctx, http.MethodPut, e.scimPath("Users/"+member.ID), user, &user)
+	if err != nil {
+		return fmt.Errorf("error updating SCIM user %s: %w", member.Login, err)
+	}
+	e.store(user)
+	return nil
+}
+
+// Remove deactivates the SCIM user, which suspends the managed user
+func (e *EMU) Remove(ctx context.Context, member sync.Member) error {
+	patch := scimPatch{
+		Schemas: []string{scimSchemaPatch},
+		Operations: []scimOperation{
+			{Op: "replace", Path: "active", Value: false},
+		},
+	}
+	err := e.scimDo(ctx, http.MethodPatch, e.scimPath("Users/"+member.ID), patch, nil)
+	if err != nil {
+		return fmt.Errorf("error deactivating SCIM user %s: %w", member.Login, err)
+	}
+	for i := range e.users {
+		if e.users[i].ID == member.ID {
+			e.users[i].Active = false
+		}
+	}
+	return nil
+}
+
+// Owners returns the enterprise owners as SCIM users. Managed user logins are the normalized
+// SCIM user name followed by the short code of the enterprise.
+func (e *EMU) Owners(ctx context.Context) ([]sync.Member, error) {
+	owners, err := e.GitHub.Owners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := e.scimUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := map[string]bool{}
+	for _, owner := range owners {
+		logins[trimShortCode(owner.Login)] = true
+	}
+
+	members := []sync.Member{}
+	for _, u := range users {
+		if logins[normalizeUserName(u.UserName)] {
+			members = append(members, sync.Member{ID: u.ID, Login: u.UserName, Email: u.primaryEmail()})
+		}
+	}
+	return members, nil
+}
+
+// UserNames returns the logins with the managed user logins replaced by the SCIM user names they are
+// derived from, e.g. admin_acme by admin@example.com. Logins that are user names are kept as they are.
+func (e *EMU) UserNames(ctx context.Context, logins []string) ([]string, error) {
+	users, err := e.scimUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userNames := []string{}
+	for _, login := range logins {
+		if strings.Contains(login, "@") {
+			userNames = append(userNames, login)
+			continue
+		}
+		found := false
+		for _, u := range users {
+			if normalizeUserName(u.UserName) == trimShortCode(login) {
+				slog.DebugContext(ctx, "Managed user login mapped to SCIM user name", "login", login, "userName", u.UserName)
+				userNames = append(userNames, u.UserName)
+				found = true
+			}
+		}
+		if !found {
+			slog.WarnContext(ctx, "No SCIM user for managed user login", "login", login)
+			userNames = append(userNames, login)
+		}
+	}
+	return userNames, nil
+}
+
+// scimGroups returns all SCIM groups of the enterprise
+func (e *EMU) scimGroups(ctx context.Context) ([]scimGroup, error) {
+	if e.groups == nil {
+		slog.InfoContext(ctx, "Loading SCIM groups", "enterprise", e.config.Enterprise)
+		groups, err := scimListAll[scimGroup](ctx, e.GitHub, "Groups")
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to load SCIM groups", "error", err)
+			return nil, err
+		}
+		e.groups = groups
+		slog.InfoContext(ctx, "Loaded SCIM groups", "groups", len(e.groups))
+	}
+	return e.groups, nil
+}
+
+// findGroup returns the SCIM group provisioned for the source group. Groups are matched by external ID
+// only, display names are neither unique nor stable.
+func (e *EMU) findGroup(ctx context.Context, group string) (scimGroup, error) {
+	groups, err := e.scimGroups(ctx)
+	if err != nil {
+		return scimGroup{}, err
+	}
+	for _, g := range groups {
+		if g.ExternalID == group {
+			return g, nil
+		}
+	}
+	return scimGroup{}, fmt.Errorf("%w: %s", sync.ErrGroupNotFound, group)
+}
+
+// GroupMembers returns the members of the SCIM group of the source group, logins are SCIM user names
+func (e *EMU) GroupMembers(ctx context.Context, group string) ([]sync.Member, error) {
+	users, err := e.scimUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, err := e.findGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []sync.Member{}
+	for _, m := range g.Members {
+		member := sync.Member{ID: m.Value, Login: m.Display}
+		for _, u := range users {
+			if u.ID == m.Value {
+				member = sync.Member{ID: u.ID, Login: u.UserName, Email: u.primaryEmail(), ScimUsername: u.UserName}
+				break
+			}
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// AddGroupMember adds the SCIM user to the SCIM group of the source group
+func (e *EMU) AddGroupMember(ctx context.Context, group string, member sync.Member) error {
+	g, err := e.findGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	patch := scimPatch{
+		Schemas: []string{scimSchemaPatch},
+		Operations: []scimOperation{
+			{Op: "add", Path: "members", Value: []scimMember{{Value: member.ID, Display: member.Login}}},
+		},
+	}
+	err = e.scimDo(ctx, http.MethodPatch, e.scimPath("Groups/"+g.ID), patch, nil)
+	if err != nil {
+		return fmt.Errorf("error adding %s to SCIM group %s: %w", member.Login, g.DisplayName, err)
+	}
+	return nil
+}
+
+// RemoveGroupMember removes the SCIM user from the SCIM group of the source group
+func (e *EMU) RemoveGroupMember(ctx context.Context, group string, member sync.Member) error {
+	g, err := e.findGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	patch := scimPatch{
+		Schemas: []string{scimSchemaPatch},
+		Operations: []scimOperation{
+			{Op: "remove", Path: fmt.Sprintf("members[value eq %q]", member.ID)},
+		},
+	}
+	err = e.scimDo(ctx, http.MethodPatch, e.scimPath("Groups/"+g.ID), patch, nil)
+	if err != nil {
+		return fmt.Errorf("error removing %s from SCIM group %s: %w", member.Login, g.DisplayName, err)
+	}
+	return nil
+}
+
+// CreateGroup creates an empty SCIM group with the ID of the source group as external ID
+func (e *EMU) CreateGroup(ctx context.Context, group string, name string) error {
+	_, err := e.scimGroups(ctx)
+	if err != nil {
+		return err
+	}
+	g := scimGroup{
+		Schemas:     []string{scimSchemaGroup},
+		ExternalID:  group,
+		DisplayName: name,
+	}
+	err = e.scimDo(ctx, http.MethodPost, e.scimPath("Groups"), g, &g)
+	if err != nil {
+		return fmt.Errorf("error creating SCIM group %s: %w", name, err)
+	}
+	e.groups = append(e.groups, g)
+	return nil
+}
+
+// store replaces the cached SCIM user or adds it
+func (e *EMU) store(user scimUser) {
+	for i := range e.users {
+		if e.users[i].ID == user.ID {
+			e.users[i] = user
+			return
+		}
+	}
+	e.users = append(e.users, user)
+}
+
+// newScimUser returns the active SCIM user for the identity
+func newScimUser(identity sync.Identity) scimUser {
+	user := scimUser{
+		Schemas:     []string{scimSchemaUser},
+		ExternalID:  identity.ObjectId,
+		UserName:    scimUserName(identity),
+		DisplayName: identity.DisplayName,
+		Name: scimName{
+			GivenName:  identity.GivenName,
+			FamilyName: identity.Surname,
+			Formatted:  identity.DisplayName,
+		},
+		Active: true,
+	}
+	if identity.Email != "" {
+		user.Emails = []scimEmail{{Value: identity.Email, Type: "work", Primary: true}}
+	}
+	return user
+}
+
+// scimUserName is the user principal name like the Entra provisioning connector uses, or the email
+func scimUserName(identity sync.Identity) string {
+	if identity.UserPrincipalName != "" {
+		return identity.UserPrincipalName
+	}
+	return identity.Email
+}
+
+var invalidLoginCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// trimShortCode returns the lower case managed user login without the short code of the enterprise
+func trimShortCode(login string) string {
+	login = strings.ToLower(login)
+	if i := strings.LastIndex(login, "_"); i > 0 {
+		login = login[:i]
+	}
+	return login
+}
+
+// normalizeUserName returns the login GitHub derives from the SCIM user name without the short code
+func normalizeUserName(userName string) string {
+	userName = strings.ToLower(userName)
+	if i := strings.Index(userName, "@"); i > 0 {
+		userName = userName[:i]
+	}
+	return strings.Trim(invalidLoginCharacters.ReplaceAllString(userName, "-"), "-")
+}
@@ -0,0 +1,21 @@
+package github
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestUserNames(t *testing.T) {
+	e := &EMU{users: []scimUser{{ID: "1", UserName: "Jane.Doe@example.com"}, {ID: "2", UserName: "admin@example.com"}}}
+
+	got, err := e.UserNames(context.Background(), []string{"jane-doe_acme", "admin@example.com", "unknown_acme"})
+	if err != nil {
+		t.Fatalf("UserNames: %v", err)
+	}
+
+	want := []string{"Jane.Doe@example.com", "admin@example.com", "unknown_acme"}
+	if !slices.Equal(got, want) {
+		t.Errorf("user names = %v, want %v", got, want)
+	}
+}
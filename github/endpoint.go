@@ -0,0 +1,89 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const defaultHost = "github.com"
+
+// endpoints are the URLs of a GitHub deployment
+type endpoints struct {
+	web     string
+	rest    string
+	graphql string
+}
+
+// resolveEndpoints derives the URLs from the host, explicit URLs take precedence.
+//
+//   - github.com uses api.github.com
+//   - data residency hosts (*.ghe.com) use the api. subdomain
+//   - any other host is a GitHub Enterprise Server with /api/v3 and /api/graphql
+func resolveEndpoints(config Config) endpoints {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(config.Host), "https://"), "/")
+	if host == "" {
+		host = defaultHost
+	}
+
+	e := endpoints{web: "https://" + host}
+	switch {
+	case host == defaultHost:
+		e.rest = "https://api.github.com/"
+		e.graphql = "https://api.github.com/graphql"
+	case strings.HasSuffix(host, ".ghe.com"):
+		e.rest = "https://api." + host + "/"
+		e.graphql = "https://api." + host + "/graphql"
+	default:
+		e.rest = "https://" + host + "/api/v3/"
+		e.graphql = "https://" + host + "/api/graphql"
+	}
+
+	if config.APIURL != "" {
+		e.rest = config.APIURL
+	}
+	if config.GraphQLURL != "" {
+		e.graphql = config.GraphQLURL
+	}
+	return e
+}
+
+// newRESTClient creates the REST client for the endpoints
+func newRESTClient(httpClient *http.Client, e endpoints) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if e.rest == "https://api.github.com/" {
+		return client, nil
+	}
+	client, err := client.WithEnterpriseURLs(e.rest, e.web)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid GitHub API URL %s: %w", ErrInvalidConfig, e.rest, err)
+	}
+	return client, nil
+}
+
+// baseTransport returns the default transport that also trusts the certificates of the CA bundle
+func baseTransport(caBundle string) (http.RoundTripper, error) {
+	if caBundle == "" {
+		return http.DefaultTransport, nil
+	}
+
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading CA bundle %s: %w", ErrInvalidConfig, caBundle, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: no certificates found in CA bundle %s", ErrInvalidConfig, caBundle)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
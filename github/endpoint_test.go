@@ -0,0 +1,66 @@
+package github
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   endpoints
+	}{
+		{
+			name:   "github.com is the default",
+			config: Config{},
+			want:   endpoints{web: "https://github.com", rest: "https://api.github.com/", graphql: "https://api.github.com/graphql"},
+		},
+		{
+			name:   "data residency",
+			config: Config{Host: "https://Acme.ghe.com/"},
+			want:   endpoints{web: "https://acme.ghe.com", rest: "https://api.acme.ghe.com/", graphql: "https://api.acme.ghe.com/graphql"},
+		},
+		{
+			name:   "enterprise server",
+			config: Config{Host: "github.example.com"},
+			want:   endpoints{web: "https://github.example.com", rest: "https://github.example.com/api/v3/", graphql: "https://github.example.com/api/graphql"},
+		},
+		{
+			name:   "explicit URLs take precedence",
+			config: Config{Host: "github.example.com", APIURL: "https://proxy.example.com/rest/", GraphQLURL: "https://proxy.example.com/graphql"},
+			want:   endpoints{web: "https://github.example.com", rest: "https://proxy.example.com/rest/", graphql: "https://proxy.example.com/graphql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveEndpoints(tt.config); got != tt.want {
+				t.Errorf("endpoints = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRESTClient(t *testing.T) {
+	client, err := newRESTClient(nil, resolveEndpoints(Config{Host: "github.example.com"}))
+	if err != nil {
+		t.Fatalf("newRESTClient: %v", err)
+	}
+	if got := client.BaseURL.String(); got != "https://github.example.com/api/v3/" {
+		t.Errorf("base URL = %s, want the enterprise server API", got)
+	}
+}
+
+func TestBaseTransportInvalidBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("no certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := baseTransport(path); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"github.com/prodyna/sync-enterprise/sync"
+	"log/slog"
+	"strings"
+)
+
+// Invite invites the identity into every configured organization
+func (g *GitHub) Invite(ctx context.Context, identity sync.Identity) ([]sync.InviteResult, error) {
+	return g.InviteUser(ctx, identity.Email, identity.DisplayName)
+}
+
+// InviteUser invites the user by email into every configured organization.
+// Organizations that already have a pending invitation for the email are skipped.
+// Without organizations nobody is invited and the result is empty.
+func (g *GitHub) InviteUser(ctx context.Context, email string, name string) ([]sync.InviteResult, error) {
+	if len(g.config.InviteOrganizations) == 0 {
+		slog.InfoContext(ctx, "No organizations configured for invitations, skipping", "email", email, "name", name)
+		return []sync.InviteResult{}, nil
+	}
+
+	results := []sync.InviteResult{}
+	for _, org := range g.config.InviteOrganizations {
+		result := sync.InviteResult{
+			Organization: org,
+			Email:        email,
+		}
+
+		pending, err := g.pendingInvitations(ctx, org)
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to load pending invitations", "organization", org, "error", err)
+			result.Status = sync.InviteFailed
+			result.Reason = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if pending[strings.ToLower(email)] {
+			slog.InfoContext(ctx, "Invitation already pending", "email", email, "name", name, "organization", org)
+			result.Status = sync.InvitePending
+			results = append(results, result)
+			continue
+		}
+
+		options := &github.CreateOrgInvitationOptions{
+			Email:  github.String(email),
+			Role:   github.String(g.config.InviteRole),
+			TeamID: g.config.InviteTeamIds,
+		}
+		_, _, err = g.client.Organizations.CreateOrgInvitation(ctx, org, options)
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to invite user", "email", email, "name", name, "organization", org, "error", err)
+			result.Status = sync.InviteFailed
+			result.Reason = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		slog.InfoContext(ctx, "User invited", "email", email, "name", name, "organization", org, "role", g.config.InviteRole)
+		pending[strings.ToLower(email)] = true
+		result.Status = sync.InviteSent
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// pendingInvitations returns the lower case emails of all pending invitations of the organization
+func (g *GitHub) pendingInvitations(ctx context.Context, org string) (map[string]bool, error) {
+	if g.invitations == nil {
+		g.invitations = map[string]map[string]bool{}
+	}
+	if pending, ok := g.invitations[org]; ok {
+		return pending, nil
+	}
+
+	slog.DebugContext(ctx, "Loading pending invitations", "organization", org)
+	pending := map[string]bool{}
+	options := &github.ListOptions{PerPage: 100}
+	for {
+		invitations, response, err := g.client.Organizations.ListPendingOrgInvitations(ctx, org, options)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pending invitations of %s: %w", org, err)
+		}
+		for _, invitation := range invitations {
+			if invitation.Email != nil {
+				pending[strings.ToLower(*invitation.Email)] = true
+			}
+		}
+		if response.NextPage == 0 {
+			break
+		}
+		options.Page = response.NextPage
+	}
+
+	slog.DebugContext(ctx, "Loaded pending invitations", "organization", org, "count", len(pending))
+	g.invitations[org] = pending
+	return pending, nil
+}
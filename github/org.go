@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"github.com/prodyna/sync-enterprise/sync"
+	"log/slog"
+)
+
+// OrgMembers returns the members of the organization with their role
+func (g *GitHub) OrgMembers(ctx context.Context, org string) ([]sync.OrgMember, error) {
+	members := []sync.OrgMember{}
+	for _, role := range []string{sync.RoleAdmin, sync.RoleMember} {
+		options := &github.ListMembersOptions{
+			Role:        role,
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+		for {
+			users, resp, err := g.client.Organizations.ListMembers(ctx, org, options)
+			if err != nil {
+				slog.ErrorContext(ctx, "Unable to list organization members", "organization", org, "role", role, "error", err)
+				return nil, err
+			}
+			for _, user := range users {
+				members = append(members, sync.OrgMember{
+					Member: sync.Member{ID: user.GetNodeID(), Login: user.GetLogin()},
+					Role:   role,
+				})
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			options.Page = resp.NextPage
+		}
+	}
+	slog.DebugContext(ctx, "Loaded organization members", "organization", org, "members", len(members))
+	return members, nil
+}
+
+// SetOrgRole adds the member to the organization with the role or changes the role of the member
+func (g *GitHub) SetOrgRole(ctx context.Context, org string, member sync.Member, role string) error {
+	membership, _, err := g.client.Organizations.EditOrgMembership(ctx, member.Login, org, &github.Membership{Role: github.String(role)})
+	if err != nil {
+		return fmt.Errorf("error setting role %s of %s in %s: %w", role, member.Login, org, err)
+	}
+	slog.DebugContext(ctx, "Organization membership", "organization", org, "login", member.Login, "role", membership.GetRole(), "state", membership.GetState())
+	return nil
+}
+
+// RemoveOrgMember removes the member from the organization
+func (g *GitHub) RemoveOrgMember(ctx context.Context, org string, member sync.Member) error {
+	_, err := g.client.Organizations.RemoveOrgMembership(ctx, member.Login, org)
+	if err != nil {
+		return fmt.Errorf("error removing %s from %s: %w", member.Login, org, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"context"
+	"github.com/prodyna/sync-enterprise/sync"
+	"github.com/shurcooL/githubv4"
+	"log/slog"
+)
+
+// Owners returns the owners of the enterprise
+func (g *GitHub) Owners(ctx context.Context) ([]sync.Member, error) {
+	slog.InfoContext(ctx, "Loading owners", "enterprise", g.config.Enterprise)
+	owners := []sync.Member{}
+
+	var query struct {
+		Enterprise struct {
+			OwnerInfo struct {
+				Admins struct {
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   githubv4.String
+					}
+					Nodes []struct {
+						ID    string
+						Login string
+					}
+				} `graphql:"admins(role: OWNER, after: $after, first: $first)"`
+			}
+		} `graphql:"enterprise(slug: $slug)"`
+	}
+
+	variables := map[string]interface{}{
+		"slug":  githubv4.String(g.config.Enterprise),
+		"first": githubv4.Int(100),
+		"after": (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query owners", "error", err)
+			return nil, err
+		}
+
+		for _, n := range query.Enterprise.OwnerInfo.Admins.Nodes {
+			slog.DebugContext(ctx, "GitHub owner", "id", n.ID, "login", n.Login)
+			owners = append(owners, sync.Member{
+				ID:    n.ID,
+				Login: n.Login,
+			})
+		}
+
+		if !query.Enterprise.OwnerInfo.Admins.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["after"] = githubv4.NewString(query.Enterprise.OwnerInfo.Admins.PageInfo.EndCursor)
+	}
+
+	slog.InfoContext(ctx, "Loaded owners", "owners", len(owners))
+	return owners, nil
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	scimSchemaUser  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaPatch = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimMediaType   = "application/scim+json"
+	scimPageSize    = 100
+)
+
+type scimName struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Name        scimName    `json:"name"`
+	Emails      []scimEmail `json:"emails,omitempty"`
+	Active      bool        `json:"active"`
+}
+
+// primaryEmail returns the primary email of the user or the first one
+func (u scimUser) primaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+}
+
+type scimOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+type scimPatch struct {
+	Schemas    []string        `json:"schemas"`
+	Operations []scimOperation `json:"Operations"`
+}
+
+type scimList[T any] struct {
+	TotalResults int `json:"totalResults"`
+	ItemsPerPage int `json:"itemsPerPage"`
+	StartIndex   int `json:"startIndex"`
+	Resources    []T `json:"Resources"`
+}
+
+// scimPath returns the path of the SCIM resource of the enterprise
+func (g *GitHub) scimPath(resource string) string {
+	return fmt.Sprintf("scim/v2/enterprises/%s/%s", url.PathEscape(g.config.Enterprise), resource)
+}
+
+// scimDo sends a SCIM request and decodes the response into v if not nil
+func (g *GitHub) scimDo(ctx context.Context, method string, path string, body any, v any) error {
+	req, err := g.client.NewRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", scimMediaType)
+	if body != nil {
+		req.Header.Set("Content-Type", scimMediaType)
+	}
+	_, err = g.client.Do(ctx, req, v)
+	if err != nil {
+		return fmt.Errorf("error calling %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// scimListAll loads all pages of the SCIM resource
+func scimListAll[T any](ctx context.Context, g *GitHub, resource string) ([]T, error) {
+	all := []T{}
+	for start := 1; ; start += scimPageSize {
+		page := scimList[T]{}
+		path := fmt.Sprintf("%s?startIndex=%d&count=%d", g.scimPath(resource), start, scimPageSize)
+		err := g.scimDo(ctx, http.MethodGet, path, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Resources...)
+		if len(page.Resources) == 0 || len(all) >= page.TotalResults {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"github.com/prodyna/sync-enterprise/sync"
+	"github.com/shurcooL/githubv4"
+	"log/slog"
+	"strings"
+)
+
+// Snapshot captures the organization roles, direct team memberships and outside collaborator permissions of the member
+func (g *GitHub) Snapshot(ctx context.Context, member sync.Member) (*sync.Snapshot, error) {
+	slog.InfoContext(ctx, "Capturing snapshot", "login", member.Login)
+	snapshot := sync.Snapshot{
+		Member:        member,
+		Teams:         []sync.TeamMembership{},
+		Collaborators: []sync.RepositoryPermission{},
+	}
+
+	userId, organizations, err := g.memberOrganizations(ctx, member.Login)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.UserId = userId
+	snapshot.Organizations = organizations
+
+	for _, o := range snapshot.Organizations {
+		teams, err := g.memberTeams(ctx, o.Organization, member.Login)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Teams = append(snapshot.Teams, teams...)
+	}
+
+	repositories, err := g.collaboratorRepositories(ctx, member.Login)
+	if err != nil {
+		return nil, err
+	}
+	for _, repository := range repositories {
+		permission, err := g.collaboratorPermission(ctx, repository, member.Login)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Collaborators = append(snapshot.Collaborators, sync.RepositoryPermission{Repository: repository, Permission: permission})
+	}
+
+	slog.InfoContext(ctx, "Captured snapshot",
+		"login", member.Login,
+		"organizations", len(snapshot.Organizations),
+		"teams", len(snapshot.Teams),
+		"collaborators", len(snapshot.Collaborators))
+	return &snapshot, nil
+}
+
+// memberOrganizations returns the user ID of the enterprise member and its roles in the organizations of the enterprise
+func (g *GitHub) memberOrganizations(ctx context.Context, login string) (int64, []sync.OrgMembership, error) {
+	var userId int64
+	organizations := []sync.OrgMembership{}
+
+	var query struct {
+		Enterprise struct {
+			Members struct {
+				Nodes []struct {
+					EnterpriseUserAccount struct {
+						Login string
+						User  struct {
+							DatabaseId int64
+						}
+						Organizations struct {
+							PageInfo struct {
+								HasNextPage bool
+								EndCursor   githubv4.String
+							}
+							Edges []struct {
+								Role githubv4.String
+								Node struct {
+									Login string
+								}
+							}
+						} `graphql:"organizations(first: $first, after: $after)"`
+					} `graphql:"... on EnterpriseUserAccount"`
+				}
+			} `graphql:"members(query: $login, first: 10)"`
+		} `graphql:"enterprise(slug: $slug)"`
+	}
+
+	variables := map[string]interface{}{
+		"slug":  githubv4.String(g.config.Enterprise),
+		"login": githubv4.String(login),
+		"first": githubv4.Int(100),
+		"after": (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query enterprise membership", "login", login, "error", err)
+			return 0, nil, err
+		}
+
+		hasNextPage := false
+		for _, n := range query.Enterprise.Members.Nodes {
+			account := n.EnterpriseUserAccount
+			if !strings.EqualFold(account.Login, login) {
+				continue
+			}
+			userId = account.User.DatabaseId
+			for _, e := range account.Organizations.Edges {
+				role := sync.RoleMember
+				switch e.Role {
+				case "OWNER":
+					role = sync.RoleAdmin
+				case "UNAFFILIATED":
+					continue
+				}
+				organizations = append(organizations, sync.OrgMembership{Organization: e.Node.Login, Role: role})
+			}
+			if account.Organizations.PageInfo.HasNextPage {
+				hasNextPage = true
+				variables["after"] = githubv4.NewString(account.Organizations.PageInfo.EndCursor)
+			}
+		}
+		if userId == 0 {
+			return 0, nil, fmt.Errorf("enterprise member %s not found", login)
+		}
+
+		if !hasNextPage {
+			break
+		}
+	}
+	return userId, organizations, nil
+}
+
+// collaboratorRepositories returns the repositories (owner/name) of the enterprise the user is an outside collaborator of
+func (g *GitHub) collaboratorRepositories(ctx context.Context, login string) ([]string, error) {
+	repositories := []string{}
+
+	var query struct {
+		Enterprise struct {
+			OwnerInfo struct {
+				OutsideCollaborators struct {
+					Edges []struct {
+						Node struct {
+							Login string
+						}
+						Repositories struct {
+							PageInfo struct {
+								HasNextPage bool
+								EndCursor   githubv4.String
+							}
+							Nodes []struct {
+								NameWithOwner string
+							}
+						} `graphql:"repositories(first: $first, after: $after)"`
+					}
+				} `graphql:"outsideCollaborators(login: $login, first: 1)"`
+			}
+		} `graphql:"enterprise(slug: $slug)"`
+	}
+
+	variables := map[string]interface{}{
+		"slug":  githubv4.String(g.config.Enterprise),
+		"login": githubv4.String(login),
+		"first": githubv4.Int(100),
+		"after": (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query outside collaborator", "login", login, "error", err)
+			return nil, err
+		}
+
+		hasNextPage := false
+		for _, e := range query.Enterprise.OwnerInfo.OutsideCollaborators.Edges {
+			if !strings.EqualFold(e.Node.Login, login) {
+				continue
+			}
+			for _, r := range e.Repositories.Nodes {
+				repositories = append(repositories, r.NameWithOwner)
+			}
+			if e.Repositories.PageInfo.HasNextPage {
+				hasNextPage = true
+				variables["after"] = githubv4.NewString(e.Repositories.PageInfo.EndCursor)
+			}
+		}
+
+		if !hasNextPage {
+			break
+		}
+	}
+	return repositories, nil
+}
+
+// memberTeams returns the teams of the organization the user is a direct member of
+func (g *GitHub) memberTeams(ctx context.Context, org string, login string) ([]sync.TeamMembership, error) {
+	teams := []sync.TeamMembership{}
+
+	var query struct {
+		Organization struct {
+			Teams struct {
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   githubv4.String
+				}
+				Nodes []struct {
+					Slug    string
+					Members struct {
+						Edges []struct {
+							Role githubv4.TeamMemberRole
+							Node struct {
+								Login string
+							}
+						}
+					} `graphql:"members(query: $login, first: 10, membership: IMMEDIATE)"`
+				}
+			} `graphql:"teams(first: $first, after: $after, userLogins: $logins)"`
+		} `graphql:"organization(login: $org)"`
+	}
+
+	variables := map[string]interface{}{
+		"org":    githubv4.String(org),
+		"login":  githubv4.String(login),
+		"logins": []githubv4.String{githubv4.String(login)},
+		"first":  githubv4.Int(100),
+		"after":  (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query teams", "organization", org, "login", login, "error", err)
+			return nil, err
+		}
+
+		for _, n := range query.Organization.Teams.Nodes {
+			// teams the user only inherits from a child team have no direct membership
+			for _, e := range n.Members.Edges {
+				if strings.EqualFold(e.Node.Login, login) {
+					teams = append(teams, sync.TeamMembership{Organization: org, Team: n.Slug, Role: strings.ToLower(string(e.Role))})
+				}
+			}
+		}
+
+		if !query.Organization.Teams.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["after"] = githubv4.NewString(query.Organization.Teams.PageInfo.EndCursor)
+	}
+	return teams, nil
+}
+
+// collaboratorPermission returns the permission of the outside collaborator, e.g. read, triage, write, maintain or admin
+func (g *GitHub) collaboratorPermission(ctx context.Context, repository string, login string) (string, error) {
+	owner, name, _ := strings.Cut(repository, "/")
+
+	var query struct {
+		Repository struct {
+			Collaborators struct {
+				Edges []struct {
+					Permission githubv4.RepositoryPermission
+					Node       struct {
+						Login string
+					}
+				}
+			} `graphql:"collaborators(query: $login, first: 10, affiliation: OUTSIDE)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	variables := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(name),
+		"login": githubv4.String(login),
+	}
+	err := g.v4client.Query(ctx, &query, variables)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to query collaborator permission", "repository", repository, "login", login, "error", err)
+		return "", err
+	}
+	for _, e := range query.Repository.Collaborators.Edges {
+		if strings.EqualFold(e.Node.Login, login) {
+			return strings.ToLower(string(e.Permission)), nil
+		}
+	}
+	return "", fmt.Errorf("%s is no collaborator of %s", login, repository)
+}
+
+// Restore invites the user of the snapshot into the organizations with its role and adds it to the
+// teams and repositories again. GitHub sends invitations the user has to accept.
+func (g *GitHub) Restore(ctx context.Context, snapshot *sync.Snapshot) error {
+	if snapshot.UserId == 0 {
+		return fmt.Errorf("snapshot of %s has no user ID", snapshot.Member.Login)
+	}
+	login := snapshot.Member.Login
+
+	errs := []error{}
+	for _, o := range snapshot.Organizations {
+		role := "direct_member"
+		if o.Role == sync.RoleAdmin {
+			role = "admin"
+		}
+		_, _, err := g.client.Organizations.CreateOrgInvitation(ctx, o.Organization, &github.CreateOrgInvitationOptions{
+			InviteeID: github.Int64(snapshot.UserId),
+			Role:      github.String(role),
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to invite into organization", "login", login, "organization", o.Organization, "error", err)
+			errs = append(errs, fmt.Errorf("error inviting %s into %s: %w", login, o.Organization, err))
+			continue
+		}
+		slog.InfoContext(ctx, "Invited into organization", "login", login, "organization", o.Organization, "role", role)
+	}
+
+	for _, t := range snapshot.Teams {
+		err := g.SetTeamRole(ctx, t.Organization, t.Team, snapshot.Member, t.Role)
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to add to team", "login", login, "organization", t.Organization, "team", t.Team, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.InfoContext(ctx, "Added to team", "login", login, "organization", t.Organization, "team", t.Team, "role", t.Role)
+	}
+
+	for _, c := range snapshot.Collaborators {
+		owner, name, _ := strings.Cut(c.Repository, "/")
+		_, _, err := g.client.Repositories.AddCollaborator(ctx, owner, name, login, &github.RepositoryAddCollaboratorOptions{
+			Permission: restPermission(c.Permission),
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to add as collaborator", "login", login, "repository", c.Repository, "error", err)
+			errs = append(errs, fmt.Errorf("error adding %s to %s: %w", login, c.Repository, err))
+			continue
+		}
+		slog.InfoContext(ctx, "Added as collaborator", "login", login, "repository", c.Repository, "permission", c.Permission)
+	}
+
+	return errors.Join(errs...)
+}
+
+// restPermission converts the GraphQL repository permission into the one of the REST API
+func restPermission(permission string) string {
+	switch permission {
+	case "read":
+		return "pull"
+	case "write":
+		return "push"
+	}
+	return permission
+}
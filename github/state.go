@@ -0,0 +1,86 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// StateStore stores the sync state in a file of a repository, every save is a commit
+type StateStore struct {
+	client *github.Client
+	owner  string
+	repo   string
+	branch string
+	path   string
+	// sha is the blob of the loaded file, required to update it
+	sha string
+}
+
+// NewStateStore returns the store for the file in the repository (owner/name), the default branch if the branch is empty
+func (g *GitHub) NewStateStore(repository string, branch string, path string) (*StateStore, error) {
+	owner, repo, ok := strings.Cut(repository, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid state repository %s, expected owner/name", repository)
+	}
+	return &StateStore{
+		client: g.client,
+		owner:  owner,
+		repo:   repo,
+		branch: branch,
+		path:   path,
+	}, nil
+}
+
+// Load reads the file from the repository, a missing file is no state
+func (s *StateStore) Load(ctx context.Context) ([]byte, error) {
+	file, _, _, err := s.client.Repositories.GetContents(ctx, s.owner, s.repo, s.path, &github.RepositoryContentGetOptions{Ref: s.branch})
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		slog.InfoContext(ctx, "No state in repository", "repository", s.owner+"/"+s.repo, "path", s.path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state %s from %s/%s: %w", s.path, s.owner, s.repo, err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("state %s in %s/%s is not a file", s.path, s.owner, s.repo)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding state %s from %s/%s: %w", s.path, s.owner, s.repo, err)
+	}
+	s.sha = file.GetSHA()
+	return []byte(content), nil
+}
+
+// Save commits the file to the repository
+func (s *StateStore) Save(ctx context.Context, data []byte) error {
+	options := &github.RepositoryContentFileOptions{
+		Message: github.String("Update sync state"),
+		Content: data,
+	}
+	if s.branch != "" {
+		options.Branch = github.String(s.branch)
+	}
+
+	var resp *github.RepositoryContentResponse
+	var err error
+	if s.sha == "" {
+		resp, _, err = s.client.Repositories.CreateFile(ctx, s.owner, s.repo, s.path, options)
+	} else {
+		options.SHA = github.String(s.sha)
+		resp, _, err = s.client.Repositories.UpdateFile(ctx, s.owner, s.repo, s.path, options)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing state %s to %s/%s: %w", s.path, s.owner, s.repo, err)
+	}
+	s.sha = resp.GetContent().GetSHA()
+	slog.InfoContext(ctx, "Saved state", "repository", s.owner+"/"+s.repo, "path", s.path, "commit", resp.Commit.GetSHA())
+	return nil
+}
@@ -0,0 +1,119 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-github/v61/github"
+	"github.com/prodyna/sync-enterprise/sync"
+	"github.com/shurcooL/githubv4"
+	"log/slog"
+	"strings"
+)
+
+// TeamMembers returns the direct members of the team with their role, members of child teams are not included
+func (g *GitHub) TeamMembers(ctx context.Context, org string, team string) ([]sync.TeamMember, error) {
+	members := []sync.TeamMember{}
+
+	var query struct {
+		Organization struct {
+			Team struct {
+				ID      string
+				Members struct {
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   githubv4.String
+					}
+					Edges []struct {
+						Role githubv4.TeamMemberRole
+						Node struct {
+							ID    string
+							Login string
+						}
+					}
+				} `graphql:"members(first: $first, after: $after, membership: IMMEDIATE)"`
+			} `graphql:"team(slug: $team)"`
+		} `graphql:"organization(login: $org)"`
+	}
+
+	variables := map[string]interface{}{
+		"org":   githubv4.String(org),
+		"team":  githubv4.String(team),
+		"first": githubv4.Int(100),
+		"after": (*githubv4.String)(nil),
+	}
+
+	for {
+		err := g.v4client.Query(ctx, &query, variables)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to query team members", "organization", org, "team", team, "error", err)
+			return nil, err
+		}
+		if query.Organization.Team.ID == "" {
+			return nil, fmt.Errorf("%w: %s/%s", sync.ErrTeamNotFound, org, team)
+		}
+
+		for _, e := range query.Organization.Team.Members.Edges {
+			members = append(members, sync.TeamMember{
+				Member: sync.Member{ID: e.Node.ID, Login: e.Node.Login},
+				Role:   strings.ToLower(string(e.Role)),
+			})
+		}
+
+		if !query.Organization.Team.Members.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["after"] = githubv4.NewString(query.Organization.Team.Members.PageInfo.EndCursor)
+	}
+
+	slog.DebugContext(ctx, "Loaded team members", "organization", org, "team", team, "members", len(members))
+	return members, nil
+}
+
+// SetTeamRole adds the member to the team with the role or changes the role of the member.
+// Members that are not in the organization yet are invited by GitHub.
+func (g *GitHub) SetTeamRole(ctx context.Context, org string, team string, member sync.Member, role string) error {
+	membership, _, err := g.client.Teams.AddTeamMembershipBySlug(ctx, org, team, member.Login, &github.TeamAddTeamMembershipOptions{Role: role})
+	if err != nil {
+		return fmt.Errorf("error setting role %s of %s in %s/%s: %w", role, member.Login, org, team, err)
+	}
+	slog.DebugContext(ctx, "Team membership", "organization", org, "team", team, "login", member.Login, "role", membership.GetRole(), "state", membership.GetState())
+	return nil
+}
+
+// RemoveTeamMember removes the member from the team
+func (g *GitHub) RemoveTeamMember(ctx context.Context, org string, team string, member sync.Member) error {
+	_, err := g.client.Teams.RemoveTeamMembershipBySlug(ctx, org, team, member.Login)
+	if err != nil {
+		return fmt.Errorf("error removing %s from %s/%s: %w", member.Login, org, team, err)
+	}
+	return nil
+}
+
+// CreateTeam creates the closed team with the slug as name and removes its creator from it
+func (g *GitHub) CreateTeam(ctx context.Context, org string, team string) error {
+	created, _, err := g.client.Teams.CreateTeam(ctx, org, github.NewTeam{
+		Name:    team,
+		Privacy: github.String("closed"),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating team %s/%s: %w", org, team, err)
+	}
+	if created.GetSlug() != team {
+		slog.WarnContext(ctx, "Created team has a different slug", "organization", org, "team", team, "slug", created.GetSlug())
+	}
+
+	// GitHub makes the user of the token a maintainer, the mapped groups decide who is in the team
+	creators, err := g.TeamMembers(ctx, org, created.GetSlug())
+	if err != nil {
+		return err
+	}
+	for _, m := range creators {
+		slog.InfoContext(ctx, "Removing creator from team", "organization", org, "team", created.GetSlug(), "login", m.Login)
+		err = g.RemoveTeamMember(ctx, org, created.GetSlug(), m.Member)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
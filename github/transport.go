@@ -0,0 +1,200 @@
+package github
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 2 * time.Minute
+	// maxWait is the longest wait for a rate limit reset before giving up
+	maxWait = 15 * time.Minute
+)
+
+// retryTransport retries requests that hit primary or secondary rate limits and idempotent
+// requests that fail with network or transient server errors. It honors Retry-After and X-RateLimit-Reset and otherwise backs off
+// exponentially with jitter. The remaining rate limit budget is logged.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func newRetryTransport(base http.RoundTripper, maxRetries int) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{
+		base:       base,
+		maxRetries: maxRetries,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		// the first attempt sends the request as is, retries send a clone with a fresh body
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil {
+			logBudget(req, resp)
+		}
+
+		wait, retry := t.shouldRetry(req, resp, err, attempt)
+		if !retry || attempt >= t.maxRetries || !replayable(req) {
+			return resp, err
+		}
+
+		if err != nil {
+			slog.Warn("GitHub request failed, retrying",
+				"method", req.Method,
+				"url", req.URL.Path,
+				"attempt", attempt+1,
+				"wait", wait,
+				"error", err)
+		} else {
+			slog.Warn("GitHub request throttled or failed, retrying",
+				"method", req.Method,
+				"url", req.URL.Path,
+				"status", resp.StatusCode,
+				"attempt", attempt+1,
+				"wait", wait)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// replayable returns if the body of the request can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// idempotent returns if sending the request twice has the same effect as sending it once. A POST
+// that failed with a network or server error may have been executed, it is not sent again.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry returns if the request should be retried and how long to wait before. Rate limited
+// requests were not executed and are always retried, failed requests only if they are idempotent.
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if req.Context().Err() != nil || !idempotent(req) {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusForbidden && isRateLimited(resp):
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("X-RateLimit-Remaining") == "0" && isRateLimited(resp):
+		// GraphQL reports an exhausted primary rate limit in the body
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return backoff(attempt), idempotent(req)
+	default:
+		return 0, false
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return capWait(time.Duration(seconds) * time.Second)
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return capWait(time.Until(time.Unix(reset, 0)) + time.Second)
+		}
+	}
+	// secondary rate limits without headers ask to wait at least a minute
+	return capWait(time.Minute + backoff(attempt))
+}
+
+// isRateLimited checks the headers and the body of the response for a rate limit, the body is restored
+func isRateLimited(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" || (resp.Header.Get("X-RateLimit-Remaining") == "0" && resp.StatusCode != http.StatusOK) {
+		return true
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	text := strings.ToLower(string(body))
+	return strings.Contains(text, "rate limit") || strings.Contains(text, "rate_limited")
+}
+
+// capWait refuses to wait longer than maxWait
+func capWait(wait time.Duration) (time.Duration, bool) {
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxWait {
+		slog.Warn("GitHub rate limit resets too late, giving up", "wait", wait)
+		return 0, false
+	}
+	return wait, true
+}
+
+// backoff returns an exponential backoff, randomized between half and the full duration
+func backoff(attempt int) time.Duration {
+	wait := minBackoff << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// logBudget logs the remaining rate limit budget and warns if it is almost exhausted
+func logBudget(req *http.Request, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	resource := resp.Header.Get("X-RateLimit-Resource")
+
+	if limit > 0 && remaining*10 < limit {
+		slog.Warn("GitHub rate limit almost exhausted",
+			"resource", resource,
+			"remaining", remaining,
+			"limit", limit,
+			"reset", resp.Header.Get("X-RateLimit-Reset"))
+		return
+	}
+	slog.Debug("GitHub rate limit",
+		"url", req.URL.Path,
+		"resource", resource,
+		"remaining", remaining,
+		"limit", limit)
+}
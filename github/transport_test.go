@@ -0,0 +1,92 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		// status and header are the first response, the retry succeeds
+		status int
+		header map[string]string
+		body   string
+		want   int
+		calls  int
+	}{
+		{name: "success is not retried", method: http.MethodGet, status: http.StatusOK, want: http.StatusOK, calls: 1},
+		{name: "too many requests is retried", method: http.MethodPost, status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "0"}, want: http.StatusOK, calls: 2},
+		{name: "secondary rate limit is retried", method: http.MethodPost, status: http.StatusForbidden, header: map[string]string{"Retry-After": "0"}, want: http.StatusOK, calls: 2},
+		{name: "exhausted rate limit is retried", method: http.MethodGet, status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "0"}, want: http.StatusOK, calls: 2},
+		{name: "forbidden is not retried", method: http.MethodGet, status: http.StatusForbidden, body: "Resource not accessible", want: http.StatusForbidden, calls: 1},
+		{name: "server error of an idempotent request is retried", method: http.MethodPut, status: http.StatusBadGateway, want: http.StatusOK, calls: 2},
+		{name: "server error of a post is not retried", method: http.MethodPost, status: http.StatusBadGateway, want: http.StatusBadGateway, calls: 1},
+		{name: "not implemented is not retried", method: http.MethodGet, status: http.StatusNotImplemented, want: http.StatusNotImplemented, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls > 1 {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := newRetryTransport(nil, 1).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.want || calls != tt.calls {
+				t.Errorf("status = %d after %d calls, want %d after %d", resp.StatusCode, calls, tt.want, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryTransportNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &countingTransport{base: http.DefaultTransport}
+	if _, err := newRetryTransport(transport, 3).RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip succeeded, want the network error")
+	}
+	if transport.calls != 1 {
+		t.Errorf("calls = %d, want a post failing with a network error to be sent once", transport.calls)
+	}
+}
+
+// countingTransport counts the requests sent by the base transport
+type countingTransport struct {
+	base  http.RoundTripper
+	calls int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	return t.base.RoundTrip(req)
+}
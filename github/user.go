@@ -2,37 +2,105 @@ package github
 
 import (
 	"context"
+	"errors"
 	"github.com/google/go-github/v61/github"
+	"github.com/prodyna/sync-enterprise/sync"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 )
 
+// ErrInvalidConfig is returned by New if a file or value of the configuration can not be used
+var ErrInvalidConfig = errors.New("invalid configuration")
+
 type Config struct {
 	Enterprise string
 	Token      string
-	DryRun     bool
+	// AppId, AppPrivateKey and AppInstallationId authenticate as GitHub App instead of the token
+	AppId               int64
+	AppPrivateKey       string
+	AppInstallationId   int64
+	DryRun              bool
+	InviteOrganizations []string
+	InviteRole          string
+	InviteTeamIds       []int64
+	// MaxRetries is the number of retries of throttled or failed requests
+	MaxRetries int
+	// Host is the GitHub host, e.g. github.com, a GHE.com tenant or a GitHub Enterprise Server
+	Host string
+	// APIURL and GraphQLURL override the URLs derived from the host
+	APIURL     string
+	GraphQLURL string
+	// CABundle is a PEM file with additional trusted certificates
+	CABundle string
+	// ActivityDays loads the last contribution and login of the members within the days, 0 disables it
+	ActivityDays int
 }
 
 type GitHub struct {
 	config       Config
 	client       *github.Client
+	v4client     *githubv4.Client
 	userlist     GitHubUsers
 	enterpriseId string
+	invitations  map[string]map[string]bool
 }
 
 type GitHubUser struct {
 	ID    string
 	Login string
-	Email string
+	// Email is the SAML name ID
+	Email        string
+	ScimUsername string
+	// LastContribution and LastLogin are only loaded with ActivityDays, zero if there was none
+	LastContribution time.Time
+	LastLogin        time.Time
+	// JoinedAt is when the enterprise account was created, only loaded with ActivityDays
+	JoinedAt time.Time
 }
 
 type GitHubUsers []GitHubUser
 
 func New(ctx context.Context, config Config) (*GitHub, error) {
+	endpoints := resolveEndpoints(config)
+	slog.InfoContext(ctx, "Using GitHub", "host", endpoints.web, "api", endpoints.rest, "graphql", endpoints.graphql)
+
+	base, err := baseTransport(config.CABundle)
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to load CA bundle", "file", config.CABundle, "error", err)
+		return nil, err
+	}
+	retry := newRetryTransport(base, config.MaxRetries)
+
+	source := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})
+	if config.AppId != 0 {
+		source, err = appTokenSource(ctx, config, endpoints, retry)
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to authenticate as GitHub App", "appId", config.AppId, "error", err)
+			return nil, err
+		}
+	}
+
+	// REST and GraphQL share one client that authenticates and retries
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: source,
+			Base:   retry,
+		},
+	}
+
+	client, err := newRESTClient(httpClient, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
 	gh := GitHub{
-		config: config,
-		client: github.NewClient(nil).WithAuthToken(config.Token),
+		config:   config,
+		client:   client,
+		v4client: githubv4.NewEnterpriseClient(endpoints.graphql, httpClient),
 	}
 
 	return &gh, nil
@@ -48,20 +116,10 @@ func (g *GitHub) Users(ctx context.Context) ([]GitHubUser, error) {
 	return g.userlist, nil
 }
 
-func (g GitHub) DryRun() bool {
-	return g.config.DryRun
-}
-
 func (g *GitHub) loadMembers(ctx context.Context) error {
 	slog.InfoContext(ctx, "Loading members", "enterprise", g.config.Enterprise)
 	gitHubUsers := []GitHubUser{}
 
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: g.config.Token},
-	)
-	httpClient := oauth2.NewClient(ctx, src)
-	client := githubv4.NewClient(httpClient)
-
 	var query struct {
 		Enterprise struct {
 			Id        string
@@ -83,12 +141,16 @@ func (g *GitHub) loadMembers(ctx context.Context) error {
 									ContributionsCollection struct {
 										ContributionCalendar struct {
 											TotalContributions int
+											Weeks              []contributionWeek `graphql:"weeks @include(if: $activity)"`
 										}
 									}
 								}
 								SamlIdentity struct {
 									NameId string
 								}
+								ScimIdentity struct {
+									Username string
+								}
 							}
 						}
 					} `graphql:"externalIdentities(after: $after, first: $first)"`
@@ -99,14 +161,15 @@ func (g *GitHub) loadMembers(ctx context.Context) error {
 
 	window := 25
 	variables := map[string]interface{}{
-		"slug":  githubv4.String(g.config.Enterprise),
-		"first": githubv4.Int(window),
-		"after": (*githubv4.String)(nil),
+		"slug":     githubv4.String(g.config.Enterprise),
+		"first":    githubv4.Int(window),
+		"after":    (*githubv4.String)(nil),
+		"activity": githubv4.Boolean(g.config.ActivityDays > 0),
 	}
 
 	for offset := 0; ; offset += window {
 		slog.DebugContext(ctx, "Running query", "offset", offset, "window", window)
-		err := client.Query(ctx, &query, variables)
+		err := g.v4client.Query(ctx, &query, variables)
 		if err != nil {
 			slog.ErrorContext(ctx, "Unable to query", "error", err)
 			return err
@@ -120,9 +183,11 @@ func (g *GitHub) loadMembers(ctx context.Context) error {
 				"login", e.Node.User.Login,
 				"email", e.Node.SamlIdentity.NameId)
 			u := GitHubUser{
-				ID:    e.Node.User.ID,
-				Login: e.Node.User.Login,
-				Email: e.Node.SamlIdentity.NameId,
+				ID:               e.Node.User.ID,
+				Login:            e.Node.User.Login,
+				Email:            e.Node.SamlIdentity.NameId,
+				ScimUsername:     e.Node.ScimIdentity.Username,
+				LastContribution: lastContribution(e.Node.User.ContributionsCollection.ContributionCalendar.Weeks),
 			}
 			gitHubUsers = append(gitHubUsers, u)
 		}
@@ -134,6 +199,29 @@ func (g *GitHub) loadMembers(ctx context.Context) error {
 		variables["after"] = githubv4.NewString(query.Enterprise.OwnerInfo.SamlIdentityProvider.ExternalIdentities.PageInfo.EndCursor)
 	}
 
+	if g.config.ActivityDays > 0 {
+		// the login only matters for users without a contribution in the window
+		since := time.Now().AddDate(0, 0, -g.config.ActivityDays)
+		candidates := []string{}
+		for _, u := range gitHubUsers {
+			if u.LastContribution.Before(since) {
+				candidates = append(candidates, u.Login)
+			}
+		}
+		logins, err := g.lastLogins(ctx, since, candidates)
+		if err != nil {
+			return err
+		}
+		joined, err := g.joinDates(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range gitHubUsers {
+			gitHubUsers[i].LastLogin = logins[strings.ToLower(gitHubUsers[i].Login)]
+			gitHubUsers[i].JoinedAt = joined[strings.ToLower(gitHubUsers[i].Login)]
+		}
+	}
+
 	g.userlist = gitHubUsers
 
 	slog.InfoContext(ctx, "Loaded userlist", "users", len(g.userlist))
@@ -144,7 +232,28 @@ func (g GitHub) EnterpriseId() string {
 	return g.enterpriseId
 }
 
-func (g GitHub) InviteUser(ctx context.Context, email string, name string) error {
-	slog.WarnContext(ctx, "Method not implemented", "email", email, "name", name, "enterprise", g.config.Enterprise)
-	return nil
+// Members returns the enterprise members as sync members
+func (g *GitHub) Members(ctx context.Context) ([]sync.Member, error) {
+	users, err := g.Users(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []sync.Member{}
+	for _, user := range users {
+		member := sync.Member{
+			ID:           user.ID,
+			Login:        user.Login,
+			Email:        user.Email,
+			ScimUsername: user.ScimUsername,
+		}
+		if g.config.ActivityDays > 0 {
+			member.LastActivity = user.lastActivity()
+			if !user.JoinedAt.IsZero() {
+				member.JoinedAt = &user.JoinedAt
+			}
+		}
+		members = append(members, member)
+	}
+	return members, nil
 }
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+const (
+	FormatText    = "text"
+	FormatJSON    = "json"
+	FormatActions = "actions"
+)
+
+// Level returns the log level of the verbosity, 0=error, 1=warn, 2=info, 3=debug
+func Level(verbose int) (slog.Level, error) {
+	switch verbose {
+	case 0:
+		return slog.LevelError, nil
+	case 1:
+		return slog.LevelWarn, nil
+	case 2:
+		return slog.LevelInfo, nil
+	case 3:
+		return slog.LevelDebug, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid verbosity %d, expected 0 to 3", verbose)
+}
+
+// New creates a logger with the verbosity and format writing to w
+func New(w io.Writer, verbose int, format string) (*slog.Logger, error) {
+	level, err := Level(verbose)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	switch format {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatActions:
+		return slog.New(newActionsHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %s, expected text, json or actions", format)
+}
+
+// actionsHandler writes GitHub Actions workflow commands, so warnings and errors
+// show up as annotations and debug messages only with step debug logging enabled
+type actionsHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	inner slog.Handler
+}
+
+func newActionsHandler(w io.Writer, opts *slog.HandlerOptions) *actionsHandler {
+	return &actionsHandler{
+		w:  w,
+		mu: &sync.Mutex{},
+		inner: slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level: opts.Level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				// the runner adds time stamps and the command carries the level
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}),
+	}
+}
+
+func (h *actionsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *actionsHandler) Handle(ctx context.Context, r slog.Record) error {
+	prefix := ""
+	switch {
+	case r.Level >= slog.LevelError:
+		prefix = "::error::"
+	case r.Level >= slog.LevelWarn:
+		prefix = "::warning::"
+	case r.Level < slog.LevelInfo:
+		prefix = "::debug::"
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, prefix)
+	if err != nil {
+		return err
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *actionsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &actionsHandler{w: h.w, mu: h.mu, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *actionsHandler) WithGroup(name string) slog.Handler {
+	return &actionsHandler{w: h.w, mu: h.mu, inner: h.inner.WithGroup(name)}
+}
@@ -2,30 +2,50 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"github.com/prodyna/sync-enterprise/actions"
 	"github.com/prodyna/sync-enterprise/azure"
 	"github.com/prodyna/sync-enterprise/config"
 	"github.com/prodyna/sync-enterprise/github"
+	"github.com/prodyna/sync-enterprise/logging"
 	"github.com/prodyna/sync-enterprise/meta"
+	"github.com/prodyna/sync-enterprise/report"
 	"github.com/prodyna/sync-enterprise/sync"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	exitSuccess = 0
+	// exitFailure is used for unexpected errors, e.g. when Azure or GitHub are not reachable
+	exitFailure        = 1
+	exitPartialFailure = 2
+	exitAborted        = 3
+	exitConfigError    = 4
 )
 
 func main() {
 	ctx := context.Background()
 
-	opts := &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
-	slog.SetDefault(logger)
-
-	slog.SetDefault(logger)
 	c, err := config.New()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitSuccess)
+	}
 	if err != nil {
 		slog.Error("Unable to create config", "error", err)
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
+
+	logger, err := logging.New(os.Stdout, c.Verbose, c.LogFormat)
+	if err != nil {
+		slog.Error("Unable to create logger", "error", err)
+		os.Exit(exitConfigError)
+	}
+	slog.SetDefault(logger)
 	slog.Info("Starting Sync Enterprise", "version", meta.Version)
 	slog.Info("Configuration",
 		"githubEnterprise", c.GitHub.Enterprise,
@@ -33,42 +53,327 @@ func main() {
 		"azureClientId", c.Azure.ClientId,
 		"azureClientSecret", "***",
 		"azureTenantId", c.Azure.TenantId,
-		"azureGroup", c.Azure.Group,
-		"dryRun", c.DryRun)
-
-	az, err := azure.New(ctx, azure.Config{
-		AzureClientId:     c.Azure.ClientId,
-		AzureClientSecret: c.Azure.ClientSecret,
-		AzureTenantId:     c.Azure.TenantId,
-		AzureGroup:        c.Azure.Group,
-	})
-	if err != nil {
-		slog.Error("Unable to create Azure client", "error", err)
-		os.Exit(1)
+		"azureGroups", c.Azure.Groups,
+		"azureExcludeGroups", c.Azure.ExcludeGroups,
+		"azureTransitive", c.Azure.Transitive,
+		"azureMaxRetries", c.Azure.MaxRetries,
+		"azureAuth", c.Azure.Auth,
+		"azureClientCertificate", c.Azure.CertificateFile,
+		"dryRun", c.DryRun,
+		"command", c.Command,
+		"planFile", c.PlanFile,
+		"maxDeleteCount", c.GuardRails.MaxDeleteCount,
+		"maxDeletePercent", c.GuardRails.MaxDeletePercent,
+		"maxUnmatchable", c.GuardRails.MaxUnmatchable,
+		"allowMassDelete", c.GuardRails.AllowMassDelete,
+		"protectedLogins", c.Protection.Logins,
+		"protectedEmails", c.Protection.Emails,
+		"protectedPatterns", c.Protection.Patterns,
+		"protectEnterpriseOwners", c.Protection.Owners,
+		"removeDisabled", c.Policy.RemoveDisabled,
+		"removeGuests", c.Policy.RemoveGuests,
+		"inactiveDays", c.Policy.InactiveDays,
+		"removeInactive", c.Policy.RemoveInactive,
+		"matchStrategies", c.MatchStrategies,
+		"reports", c.Reports,
+		"verbose", c.Verbose,
+		"logFormat", c.LogFormat,
+		"failFast", c.FailFast,
+		"orgMappings", c.OrgMappings,
+		"teamMappings", c.TeamMappings,
+		"createTeams", c.CreateTeams,
+		"gracePeriod", c.State.GracePeriod,
+		"stateFile", c.State.File,
+		"stateRepository", c.State.Repository,
+		"stateBranch", c.State.Branch,
+		"snapshotDir", c.SnapshotDir,
+		"restoreFile", c.RestoreFile,
+		"githubInviteOrganizations", c.GitHub.InviteOrganizations,
+		"githubInviteRole", c.GitHub.InviteRole,
+		"githubInviteTeamIds", c.GitHub.InviteTeamIds,
+		"githubMaxRetries", c.GitHub.MaxRetries,
+		"githubAppId", c.GitHub.AppId,
+		"githubAppInstallationId", c.GitHub.AppInstallationId,
+		"githubHost", c.GitHub.Host,
+		"githubApiUrl", c.GitHub.ApiUrl,
+		"githubGraphqlUrl", c.GitHub.GraphqlUrl,
+		"githubCaBundle", c.GitHub.CaBundle)
+
+	// restore only recreates the memberships of a snapshot and does not need Azure
+	var az *azure.Azure
+	if c.Command != config.CommandRestore {
+		az, err = azure.New(ctx, azure.Config{
+			AzureClientId:      c.Azure.ClientId,
+			AzureClientSecret:  c.Azure.ClientSecret,
+			AzureTenantId:      c.Azure.TenantId,
+			AzureGroups:        c.Azure.Groups,
+			AzureExcludeGroups: c.Azure.ExcludeGroups,
+			AzureTransitive:    c.Azure.Transitive,
+			AzureMaxRetries:    c.Azure.MaxRetries,
+
+			AzureAuth:                c.Azure.Auth,
+			AzureCertificateFile:     c.Azure.CertificateFile,
+			AzureCertificatePassword: c.Azure.CertificatePassword,
+		})
+		if err != nil {
+			slog.Error("Unable to create Azure client", "error", err)
+			if errors.Is(err, azure.ErrInvalidConfig) {
+				os.Exit(exitConfigError)
+			}
+			os.Exit(exitFailure)
+		}
+		slog.Info("Connected to azure",
+			"auth", c.Azure.Auth,
+			"tenantId", c.Azure.TenantId,
+			"clientId", c.Azure.ClientId,
+			"groups", c.Azure.Groups,
+			"excludeGroups", c.Azure.ExcludeGroups)
 	}
-	slog.Info("Connected to azure",
-		"tenantId", c.Azure.TenantId,
-		"clientId", c.Azure.ClientId,
-		"group", c.Azure.Group)
 
-	gh, nil := github.New(ctx, github.Config{
+	ghConfig := github.Config{
 		Enterprise: c.GitHub.Enterprise,
 		Token:      c.GitHub.Token,
 		DryRun:     c.DryRun,
-	})
+
+		InviteOrganizations: c.GitHub.InviteOrganizations,
+		InviteRole:          c.GitHub.InviteRole,
+		InviteTeamIds:       c.GitHub.InviteTeamIds,
+		MaxRetries:          c.GitHub.MaxRetries,
+
+		AppId:             c.GitHub.AppId,
+		AppPrivateKey:     c.GitHub.AppPrivateKey,
+		AppInstallationId: c.GitHub.AppInstallationId,
+
+		Host:       c.GitHub.Host,
+		APIURL:     c.GitHub.ApiUrl,
+		GraphQLURL: c.GitHub.GraphqlUrl,
+		CABundle:   c.GitHub.CaBundle,
+
+		ActivityDays: c.Policy.InactiveDays,
+	}
+	var gh sync.MembershipTarget
+	var client *github.GitHub
+	var emu *github.EMU
+	if c.GitHub.Mode == config.ModeEMU {
+		emu, err = github.NewEMU(ctx, ghConfig)
+		gh = emu
+	} else {
+		client, err = github.New(ctx, ghConfig)
+		gh = client
+	}
 	if err != nil {
 		slog.Error("Unable to create GitHub client", "error", err)
-		os.Exit(1)
-
+		if errors.Is(err, github.ErrInvalidConfig) {
+			os.Exit(exitConfigError)
+		}
+		os.Exit(exitFailure)
+	}
+	if emu != nil {
+		client = emu.GitHub
 	}
 	slog.Info("Connected to GitHub",
 		"enterprise", c.GitHub.Enterprise,
+		"mode", c.GitHub.Mode,
 		"token", "***")
+	if c.GitHub.Mode == config.ModeInvite && len(c.GitHub.InviteOrganizations) == 0 {
+		slog.Warn("No organizations configured for invitations, missing users are not invited")
+	}
 
-	err = sync.Sync(ctx, *az, *gh)
+	matchStrategies := []sync.MatchStrategy{}
+	for _, name := range c.MatchStrategies {
+		strategy, err := sync.ParseMatchStrategy(name)
+		if err != nil {
+			slog.Error("Invalid match strategy", "error", err)
+			os.Exit(exitConfigError)
+		}
+		matchStrategies = append(matchStrategies, strategy)
+	}
+
+	orgMappings := []sync.OrgMapping{}
+	for _, m := range c.OrgMappings {
+		orgMappings = append(orgMappings, sync.OrgMapping{Group: m.Group, Organization: m.Organization, Role: m.Role})
+	}
+
+	teamMappings := []sync.TeamMapping{}
+	for _, m := range c.TeamMappings {
+		teamMappings = append(teamMappings, sync.TeamMapping{Group: m.Group, Organization: m.Organization, Team: m.Team, Role: m.Role})
+	}
+
+	// managed users are members by their SCIM user name, logins with the short code are mapped to it
+	protectedLogins := c.Protection.Logins
+	if emu != nil {
+		protectedLogins, err = emu.UserNames(ctx, protectedLogins)
+		if err != nil {
+			slog.Error("Unable to map protected logins to SCIM user names", "error", err)
+			os.Exit(exitFailure)
+		}
+	}
+
+	// Enterprise Managed Users mirror the Azure groups into SCIM groups
+	groups := []sync.GroupMirror{}
+	if emu != nil {
+		for id, name := range az.Groups() {
+			groups = append(groups, sync.GroupMirror{Group: id, Name: name})
+		}
+		slices.SortFunc(groups, func(a, b sync.GroupMirror) int { return strings.Compare(a.Group, b.Group) })
+	}
+
+	var stateStore sync.StateStore = sync.FileStateStore{Path: c.State.File}
+	if c.State.Repository != "" {
+		stateStore, err = client.NewStateStore(c.State.Repository, c.State.Branch, c.State.File)
+		if err != nil {
+			slog.Error("Invalid state repository", "error", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	syncConfig := sync.Config{
+		DryRun:           c.DryRun,
+		MaxDeletes:       c.GuardRails.MaxDeleteCount,
+		MaxDeletePercent: c.GuardRails.MaxDeletePercent,
+		MaxUnmatchable:   c.GuardRails.MaxUnmatchable,
+		AllowMassDelete:  c.GuardRails.AllowMassDelete,
+		Protection: sync.Protection{
+			Logins:   protectedLogins,
+			Emails:   c.Protection.Emails,
+			Patterns: c.Protection.Patterns,
+			Owners:   c.Protection.Owners,
+		},
+		Policy: sync.Policy{
+			RemoveDisabled: c.Policy.RemoveDisabled,
+			RemoveGuests:   c.Policy.RemoveGuests,
+			InactiveDays:   c.Policy.InactiveDays,
+			RemoveInactive: c.Policy.RemoveInactive,
+		},
+		MatchStrategies: matchStrategies,
+		FailFast:        c.FailFast,
+		OrgMappings:     orgMappings,
+		TeamMappings:    teamMappings,
+		CreateTeams:     c.CreateTeams,
+		Groups:          groups,
+		GracePeriod:     c.State.GracePeriod,
+		StateStore:      stateStore,
+		SnapshotDir:     c.SnapshotDir,
+	}
+	switch c.Command {
+	case config.CommandSync:
+		r, err := sync.Sync(ctx, az, gh, syncConfig)
+		if r != nil {
+			writeReports(c.Reports, r)
+			publishActions(c.Reports, r)
+		}
+		if err != nil {
+			slog.Error("Unable to sync", "error", err)
+			os.Exit(exitCode(err))
+		}
+	case config.CommandPlan:
+		plan, err := sync.NewPlan(ctx, az, gh, syncConfig)
+		if err != nil {
+			slog.Error("Unable to create plan", "error", err)
+			os.Exit(exitFailure)
+		}
+		err = sync.CheckPlan(plan, syncConfig)
+		if err != nil {
+			slog.Error("Plan rejected", "error", err)
+			os.Exit(exitCode(err))
+		}
+		err = sync.WritePlan(c.PlanFile, plan)
+		if err != nil {
+			slog.Error("Unable to write plan", "error", err)
+			os.Exit(exitFailure)
+		}
+		slog.Info("Plan written", "file", c.PlanFile, "actions", len(plan.Actions), "stay", plan.Stay, "protected", len(plan.Protected))
+	case config.CommandApply:
+		plan, err := sync.ReadPlan(c.PlanFile)
+		if err != nil {
+			slog.Error("Unable to read plan", "error", err)
+			os.Exit(exitFailure)
+		}
+		r, err := sync.Apply(ctx, az, gh, plan, syncConfig)
+		if r != nil {
+			writeReports(c.Reports, r)
+			publishActions(c.Reports, r)
+		}
+		if err != nil {
+			slog.Error("Unable to apply plan", "error", err)
+			os.Exit(exitCode(err))
+		}
+	case config.CommandRestore:
+		snapshot, err := sync.ReadSnapshot(c.RestoreFile)
+		if err != nil {
+			slog.Error("Unable to read snapshot", "error", err)
+			os.Exit(exitFailure)
+		}
+		err = sync.Restore(ctx, gh, snapshot, syncConfig)
+		if err != nil {
+			slog.Error("Unable to restore", "error", err)
+			os.Exit(exitFailure)
+		}
+		slog.Info("Restored", "login", snapshot.Member.Login, "file", c.RestoreFile)
+	}
+}
+
+// exitCode returns the exit code for the error of a sync
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.Is(err, sync.ErrAborted):
+		return exitAborted
+	case errors.Is(err, sync.ErrPartialFailure):
+		return exitPartialFailure
+	}
+	return exitFailure
+}
+
+// syncGroups maps the Azure groups to the SCIM groups of an EMU enterprise after the users are synced,
+// a failure is a partial failure of the run
+func writeReports(reports []config.Report, r *sync.Report) {
+	for _, rep := range reports {
+		err := report.Write(rep.Format, rep.Path, r)
+		if err != nil {
+			slog.Error("Unable to write report", "format", rep.Format, "path", rep.Path, "error", err)
+			os.Exit(exitFailure)
+		}
+		slog.Info("Report written", "format", rep.Format, "path", rep.Path)
+	}
+}
+
+// publishActions writes the job summary and the step outputs when running inside GitHub Actions
+func publishActions(reports []config.Report, r *sync.Report) {
+	if !actions.Running() {
+		return
+	}
+
+	path := ""
+	for _, rep := range reports {
+		if rep.Format == report.FormatJSON {
+			path = rep.Path
+			break
+		}
+	}
+	if path == "" {
+		path = "sync-enterprise-report.json"
+		writeReports([]config.Report{{Format: report.FormatJSON, Path: path}}, r)
+	}
+
+	err := actions.WriteSummary(r)
 	if err != nil {
-		slog.Error("Unable to sync", "error", err)
-		os.Exit(1)
+		slog.Warn("Unable to write job summary", "error", err)
 	}
 
+	err = actions.WriteOutputs(map[string]string{
+		"invited":   strconv.Itoa(r.Summary[string(sync.ClassInvite)]),
+		"removed":   strconv.Itoa(r.Summary[string(sync.ClassDelete)]),
+		"updated":   strconv.Itoa(r.Summary[string(sync.ClassUpdate)]),
+		"failed":    strconv.Itoa(r.Summary[string(sync.ClassFailed)]),
+		"skipped":   strconv.Itoa(r.Summary[string(sync.ClassSkipped)]),
+		"protected": strconv.Itoa(r.Summary[string(sync.ClassProtected)]),
+		"unmatched": strconv.Itoa(r.Summary[string(sync.ClassUnmatched)]),
+		"stay":      strconv.Itoa(r.Summary[string(sync.ClassStay)]),
+		"report":    path,
+	})
+	if err != nil {
+		slog.Warn("Unable to write step outputs", "error", err)
+	}
 }
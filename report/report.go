@@ -0,0 +1,134 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/prodyna/sync-enterprise/sync"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// Write renders the report in the format to the file
+func Write(format string, path string, r *sync.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report %s: %w", path, err)
+	}
+	defer f.Close()
+
+	err = Render(format, f, r)
+	if err != nil {
+		return fmt.Errorf("error writing report %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Render renders the report in the format to the writer
+func Render(format string, w io.Writer, r *sync.Report) error {
+	switch format {
+	case FormatJSON:
+		return renderJSON(w, r)
+	case FormatCSV:
+		return renderCSV(w, r)
+	case FormatMarkdown:
+		return renderMarkdown(w, r)
+	}
+	return fmt.Errorf("unknown report format %s", format)
+}
+
+func renderJSON(w io.Writer, r *sync.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var header = []string{"classification", "action", "login", "id", "organization", "team", "group", "email", "displayName", "matchedBy", "groups", "reason", "outcome", "error"}
+
+func row(e sync.ReportEntry) []string {
+	return []string{
+		string(e.Classification),
+		e.Action,
+		e.Login,
+		e.ID,
+		e.Organization,
+		e.Team,
+		e.Group,
+		e.Email,
+		e.DisplayName,
+		string(e.MatchedBy),
+		strings.Join(e.Groups, ";"),
+		e.Reason,
+		e.Outcome,
+		e.Error,
+	}
+}
+
+func renderCSV(w io.Writer, r *sync.Report) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, e := range r.Entries {
+		err = writer.Write(row(e))
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func renderMarkdown(w io.Writer, r *sync.Report) error {
+	fmt.Fprintf(w, "# Sync report\n\n")
+	fmt.Fprintf(w, "Created at %s", r.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	if r.DryRun {
+		fmt.Fprintf(w, " (dry-run)")
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	classifications := []string{}
+	for c := range r.Summary {
+		classifications = append(classifications, c)
+	}
+	sort.Strings(classifications)
+	fmt.Fprintf(w, "| Classification | Count |\n|---|---:|\n")
+	for _, c := range classifications {
+		fmt.Fprintf(w, "| %s | %d |\n", c, r.Summary[c])
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "|")
+	for _, h := range header {
+		fmt.Fprintf(w, " %s |", h)
+	}
+	fmt.Fprintf(w, "\n|")
+	for range header {
+		fmt.Fprintf(w, "---|")
+	}
+	fmt.Fprintf(w, "\n")
+	for _, e := range r.Entries {
+		fmt.Fprintf(w, "|")
+		for _, cell := range row(e) {
+			fmt.Fprintf(w, " %s |", escapeMarkdown(cell))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	return nil
+}
+
+// escapeMarkdown makes the value safe to be used in a table cell
+func escapeMarkdown(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/prodyna/sync-enterprise/sync"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *sync.Report {
+	return &sync.Report{
+		CreatedAt: time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+		DryRun:    true,
+		Summary:   map[string]int{"stay": 1, "dry-run": 1},
+		Entries: []sync.ReportEntry{
+			{Classification: sync.ClassStay, Login: "alice", Email: "alice@example.com", MatchedBy: sync.MatchMail, Groups: []string{"developers", "admins"}},
+			{Classification: sync.ClassDryRun, Action: "delete", Login: "bob", Reason: "not in source | left", Outcome: "dry-run"},
+		},
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := Render(FormatJSON, &b, testReport()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	r := sync.Report{}
+	if err := json.Unmarshal(b.Bytes(), &r); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !r.DryRun || len(r.Entries) != 2 || r.Entries[1].Action != "delete" || r.Summary["stay"] != 1 {
+		t.Errorf("report = %+v, want the rendered report", r)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := Render(FormatCSV, &b, testReport()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 || !slices.Equal(records[0], header) {
+		t.Fatalf("records = %v, want the header and two entries", records)
+	}
+	if groups := records[1][slices.Index(header, "groups")]; groups != "developers;admins" {
+		t.Errorf("groups = %q, want developers;admins", groups)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := Render(FormatMarkdown, &b, testReport()); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	markdown := b.String()
+	for _, want := range []string{"(dry-run)", "| dry-run | 1 |", `not in source \| left`} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown does not contain %q:\n%s", want, markdown)
+		}
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if err := Render("xml", &bytes.Buffer{}, testReport()); err == nil {
+		t.Error("unknown format is rendered")
+	}
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeSource is an identity source with fixed identities
+type fakeSource struct {
+	identities []Identity
+}
+
+func (s *fakeSource) Identities(ctx context.Context) ([]Identity, error) {
+	return s.identities, nil
+}
+
+// fakeTarget is a membership target with fixed members and owners that records invitations and removals,
+// removals fail with removeErr
+type fakeTarget struct {
+	members   []Member
+	owners    []Member
+	invited   []Identity
+	removed   []Member
+	removeErr error
+}
+
+func (t *fakeTarget) Members(ctx context.Context) ([]Member, error) {
+	return t.members, nil
+}
+
+func (t *fakeTarget) Invite(ctx context.Context, identity Identity) ([]InviteResult, error) {
+	t.invited = append(t.invited, identity)
+	return []InviteResult{{Organization: "org", Email: identity.Email, Status: InviteSent}}, nil
+}
+
+func (t *fakeTarget) Remove(ctx context.Context, member Member) error {
+	if t.removeErr != nil {
+		return t.removeErr
+	}
+	t.removed = append(t.removed, member)
+	return nil
+}
+
+func (t *fakeTarget) Owners(ctx context.Context) ([]Member, error) {
+	return t.owners, nil
+}
+
+// fakeGroups is a group source with fixed identities by group
+type fakeGroups map[string][]Identity
+
+func (g fakeGroups) Identities(ctx context.Context) ([]Identity, error) {
+	return nil, nil
+}
+
+func (g fakeGroups) GroupIdentities(ctx context.Context, group string) ([]Identity, error) {
+	return g[group], nil
+}
+
+// fakeTeams is a membership target with fixed team members, teams missing in it do not exist
+type fakeTeams struct {
+	fakeTarget
+	teams map[string][]TeamMember
+}
+
+func (t *fakeTeams) TeamMembers(ctx context.Context, org string, team string) ([]TeamMember, error) {
+	members, ok := t.teams[org+"/"+team]
+	if !ok {
+		return nil, ErrTeamNotFound
+	}
+	return members, nil
+}
+
+func (t *fakeTeams) SetTeamRole(ctx context.Context, org string, team string, member Member, role string) error {
+	return nil
+}
+
+func (t *fakeTeams) RemoveTeamMember(ctx context.Context, org string, team string, member Member) error {
+	return nil
+}
+
+func (t *fakeTeams) CreateTeam(ctx context.Context, org string, team string) error {
+	return nil
+}
+
+// fakeGroupTarget is a membership target with fixed group members by group ID, groups missing in it do not exist
+type fakeGroupTarget struct {
+	fakeTarget
+	groups map[string][]Member
+}
+
+func (t *fakeGroupTarget) GroupMembers(ctx context.Context, group string) ([]Member, error) {
+	members, ok := t.groups[group]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	return members, nil
+}
+
+func (t *fakeGroupTarget) AddGroupMember(ctx context.Context, group string, member Member) error {
+	return nil
+}
+
+func (t *fakeGroupTarget) RemoveGroupMember(ctx context.Context, group string, member Member) error {
+	return nil
+}
+
+func (t *fakeGroupTarget) CreateGroup(ctx context.Context, group string, name string) error {
+	return nil
+}
+
+// memoryStore is a state store that keeps the state in memory and counts the saves
+type memoryStore struct {
+	data  []byte
+	saves int
+}
+
+func (s *memoryStore) Load(ctx context.Context) ([]byte, error) {
+	return s.data, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, data []byte) error {
+	s.data = data
+	s.saves++
+	return nil
+}
+
+// testNow is the fixed time of the tests
+var testNow = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// daysAgo returns the time the days before testNow
+func daysAgo(days int) *time.Time {
+	t := testNow.AddDate(0, 0, -days)
+	return &t
+}
+
+// summary returns the type and the login, or the email of invitations, of the actions
+func summary(actions []Action) []string {
+	list := []string{}
+	for _, a := range actions {
+		switch a.Type {
+		case Invite:
+			list = append(list, fmt.Sprintf("%s:%s", a.Type, a.Email))
+		case OrgAdd, OrgRemove, OrgRole:
+			list = append(list, fmt.Sprintf("%s:%s:%s", a.Type, a.Organization, a.Login))
+		case TeamAdd, TeamRemove, TeamRole:
+			list = append(list, fmt.Sprintf("%s:%s/%s:%s", a.Type, a.Organization, a.Team, a.Login))
+		case TeamCreate:
+			list = append(list, fmt.Sprintf("%s:%s/%s", a.Type, a.Organization, a.Team))
+		case GroupAdd, GroupRemove:
+			list = append(list, fmt.Sprintf("%s:%s:%s", a.Type, a.GroupName, a.Login))
+		case GroupCreate:
+			list = append(list, fmt.Sprintf("%s:%s", a.Type, a.GroupName))
+		default:
+			list = append(list, fmt.Sprintf("%s:%s", a.Type, a.Login))
+		}
+	}
+	return list
+}
+
+// testProtector returns the protector of the target, failing the test on errors
+func testProtector(t *testing.T, target MembershipTarget, protection Protection) *protector {
+	t.Helper()
+	p, err := newProtector(context.Background(), target, protection)
+	if err != nil {
+		t.Fatalf("newProtector: %v", err)
+	}
+	return p
+}
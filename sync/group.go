@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// GroupMirror mirrors the members of a source group into a group of the target
+type GroupMirror struct {
+	// Group is the ID of the source group, it identifies the group of the target
+	Group string
+	Name  string
+}
+
+// ErrGroupNotFound is returned by GroupTarget.GroupMembers if the group does not exist
+var ErrGroupNotFound = errors.New("group not found")
+
+// GroupTarget manages the members of the groups of the target that mirror source groups, e.g. SCIM
+// groups. The groups are identified by the ID of the source group, not by their name.
+type GroupTarget interface {
+	GroupMembers(ctx context.Context, group string) ([]Member, error)
+	AddGroupMember(ctx context.Context, group string, member Member) error
+	RemoveGroupMember(ctx context.Context, group string, member Member) error
+	// CreateGroup creates the group without members
+	CreateGroup(ctx context.Context, group string, name string) error
+}
+
+// reconcileGroups returns the actions that make the members of the mirrored groups match the source
+// groups. Only members of the target can be added, missing groups are created.
+func reconcileGroups(ctx context.Context, source IdentitySource, target MembershipTarget, members []Member, deleted map[string]bool, protector *protector, config Config) ([]Action, map[string]MappedSize, error) {
+	groups, ok := source.(GroupSource)
+	if !ok {
+		return nil, nil, errors.New("source does not support groups")
+	}
+	mirrors, ok := target.(GroupTarget)
+	if !ok {
+		return nil, nil, errors.New("target does not support groups")
+	}
+
+	byLogin := map[string]Member{}
+	for _, member := range members {
+		byLogin[strings.ToLower(member.Login)] = member
+	}
+
+	actions := []Action{}
+	sizes := map[string]MappedSize{}
+	for _, g := range config.Groups {
+		logins, err := groupLogins(ctx, groups, g.Group, members, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		desired := map[string]bool{}
+		for _, login := range logins {
+			desired[login] = true
+		}
+
+		current, err := mirrors.GroupMembers(ctx, g.Group)
+		switch {
+		case errors.Is(err, ErrGroupNotFound):
+			slog.InfoContext(ctx, "Group does not exist", "group", g.Name)
+			actions = append(actions, groupAction(GroupCreate, g, Member{}, "group does not exist"))
+			current = []Member{}
+		case err != nil:
+			return nil, nil, fmt.Errorf("error loading members of group %s: %w", g.Name, err)
+		}
+		slog.InfoContext(ctx, "Checking group", "group", g.Name, "members", len(current), "desired", len(desired))
+		sizes[g.Group] = MappedSize{Members: len(current), Desired: len(desired)}
+
+		found := map[string]bool{}
+		for _, m := range current {
+			login := strings.ToLower(m.Login)
+			found[login] = true
+			if desired[login] || deleted[login] {
+				continue
+			}
+			if protected, why := protector.protects(m); protected {
+				slog.InfoContext(ctx, "Member not desired but protected", "group", g.Name, "login", m.Login, "reason", why)
+				continue
+			}
+			actions = append(actions, groupAction(GroupRemove, g, m, "not in group "+g.Name))
+		}
+		for _, login := range logins {
+			if found[login] || deleted[login] {
+				continue
+			}
+			found[login] = true
+			actions = append(actions, groupAction(GroupAdd, g, byLogin[login], "in group "+g.Name))
+		}
+	}
+	return actions, sizes, nil
+}
+
+// groupAction returns the action of the type for the member in the mirrored group
+func groupAction(t ActionType, g GroupMirror, member Member, reason string) Action {
+	return Action{
+		Type:      t,
+		ID:        member.ID,
+		Login:     member.Login,
+		Email:     member.Email,
+		Group:     g.Group,
+		GroupName: g.Name,
+		Reason:    reason,
+	}
+}
+
+// groupAddAction adds the member of the action to the group
+func groupAddAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Adding to group", "add to group", "added", func() error {
+		groups, ok := target.(GroupTarget)
+		if !ok {
+			return errors.New("target does not support groups")
+		}
+		return groups.AddGroupMember(ctx, a.Group, a.member())
+	})
+}
+
+// groupRemoveAction removes the member of the action from the group
+func groupRemoveAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Removing from group", "remove from group", "removed", func() error {
+		groups, ok := target.(GroupTarget)
+		if !ok {
+			return errors.New("target does not support groups")
+		}
+		return groups.RemoveGroupMember(ctx, a.Group, a.member())
+	})
+}
+
+// groupCreateAction creates the group of the action
+func groupCreateAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Creating group", "create group", "created", func() error {
+		groups, ok := target.(GroupTarget)
+		if !ok {
+			return errors.New("target does not support groups")
+		}
+		return groups.CreateGroup(ctx, a.Group, a.GroupName)
+	})
+}
@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestReconcileGroups(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	bob := Identity{ObjectId: "2", Email: "bob@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice@example.com", Email: "alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob@example.com", Email: "bob@example.com"}
+	// both groups are named developers, they are told apart by ID
+	mirrors := []GroupMirror{{Group: "g1", Name: "developers"}, {Group: "g2", Name: "developers"}}
+
+	tests := []struct {
+		name       string
+		groups     map[string][]Member
+		deleted    map[string]bool
+		protection Protection
+		actions    []string
+	}{
+		{
+			name:    "members are added and removed by group ID",
+			groups:  map[string][]Member{"g1": {bobMember}, "g2": {bobMember}},
+			actions: []string{"group-remove:developers:bob@example.com", "group-add:developers:alice@example.com"},
+		},
+		{
+			name:    "missing group is created before members are added",
+			groups:  map[string][]Member{"g2": {bobMember}},
+			actions: []string{"group-create:developers", "group-add:developers:alice@example.com"},
+		},
+		{
+			name:       "protected member is not removed",
+			groups:     map[string][]Member{"g1": {aliceMember}, "g2": {bobMember}},
+			protection: Protection{Emails: []string{"alice@example.com"}},
+			actions:    []string{},
+		},
+		{
+			name:    "deleted member is neither added nor removed",
+			groups:  map[string][]Member{"g1": {}, "g2": {bobMember}},
+			deleted: map[string]bool{"alice@example.com": true, "bob@example.com": true},
+			actions: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := fakeGroups{"g1": {alice}, "g2": {bob}}
+			target := &fakeGroupTarget{fakeTarget: fakeTarget{members: []Member{aliceMember, bobMember}}, groups: tt.groups}
+			config := Config{Groups: mirrors}
+
+			actions, sizes, err := reconcileGroups(context.Background(), source, target, target.members, tt.deleted, testProtector(t, target, tt.protection), config)
+			if err != nil {
+				t.Fatalf("reconcileGroups: %v", err)
+			}
+
+			if got := summary(actions); !slices.Equal(got, tt.actions) {
+				t.Errorf("actions = %v, want %v", got, tt.actions)
+			}
+			if sizes["g1"].Desired != 1 {
+				t.Errorf("sizes = %+v, want one desired member of g1", sizes)
+			}
+		})
+	}
+}
+
+func TestApplySkipsOutdatedGroupActions(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice@example.com", Email: "alice@example.com"}
+	source := &groupSource{fakeSource: fakeSource{identities: []Identity{alice}}, groups: fakeGroups{"g1": {alice}}}
+	target := &fakeGroupTarget{fakeTarget: fakeTarget{members: []Member{aliceMember}}, groups: map[string][]Member{"g1": {aliceMember}}}
+	config := Config{Groups: []GroupMirror{{Group: "g1", Name: "developers"}}}
+	// alice joined the group after the plan was created
+	plan := &Plan{Version: PlanVersion, Actions: []Action{groupAction(GroupAdd, config.Groups[0], aliceMember, "in group developers")}}
+
+	report, err := Apply(context.Background(), source, target, plan, config)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if report.Summary[string(ClassGroupAdd)] != 0 {
+		t.Errorf("summary = %v, want the outdated group action skipped", report.Summary)
+	}
+}
+
+// groupSource is an identity source with fixed identities and group members
+type groupSource struct {
+	fakeSource
+	groups fakeGroups
+}
+
+func (s *groupSource) GroupIdentities(ctx context.Context, group string) ([]Identity, error) {
+	return s.groups.GroupIdentities(ctx, group)
+}
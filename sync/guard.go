@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// ErrAborted is returned when a guard rail stops the run before any action is executed
+var ErrAborted = errors.New("sync aborted by guard rail")
+
+// checkGuardRails makes sure the plan does not remove an unexpectedly large part of the members,
+// which usually is caused by a wrong or incompletely loaded source.
+func checkGuardRails(plan *Plan, config Config) error {
+	deletes := 0
+	orgRemovals := map[string]int{}
+	teamRemovals := map[string]int{}
+	groupRemovals := map[string]int{}
+	for _, a := range plan.Actions {
+		switch a.Type {
+		case Delete:
+			deletes++
+		case OrgRemove:
+			orgRemovals[a.Organization]++
+		case TeamRemove:
+			teamRemovals[a.Organization+"/"+a.Team]++
+		case GroupRemove:
+			groupRemovals[a.Group]++
+		}
+	}
+
+	// unmatchable identities are no mass deletion, AllowMassDelete does not skip them
+	if config.MaxUnmatchable > 0 && len(plan.Unmatchable) > config.MaxUnmatchable {
+		return fmt.Errorf("%w: %d identities of the source can not be matched, the maximum is %d",
+			ErrAborted, len(plan.Unmatchable), config.MaxUnmatchable)
+	}
+
+	if config.AllowMassDelete {
+		if deletes > 0 || len(orgRemovals) > 0 || len(teamRemovals) > 0 || len(groupRemovals) > 0 {
+			slog.Warn("Guard rails disabled", "delete", deletes, "members", plan.Members, "organizations", len(orgRemovals), "teams", len(teamRemovals), "groups", len(groupRemovals))
+		}
+		return nil
+	}
+
+	if plan.Identities == 0 && plan.Members > 0 {
+		return fmt.Errorf("%w: source has no identities but target has %d members", ErrAborted, plan.Members)
+	}
+
+	if config.MaxDeletes > 0 && deletes > config.MaxDeletes {
+		return fmt.Errorf("%w: %d deletions exceed the maximum of %d", ErrAborted, deletes, config.MaxDeletes)
+	}
+
+	if config.MaxDeletePercent > 0 && plan.Members > 0 {
+		if deletes*100 > config.MaxDeletePercent*plan.Members {
+			return fmt.Errorf("%w: %d deletions of %d members exceed the maximum of %d%%",
+				ErrAborted, deletes, plan.Members, config.MaxDeletePercent)
+		}
+	}
+
+	err := checkRemovals("organization", plan.Organizations, orgRemovals, config)
+	if err != nil {
+		return err
+	}
+	err = checkRemovals("team", plan.Teams, teamRemovals, config)
+	if err != nil {
+		return err
+	}
+	return checkRemovals("group", plan.Groups, groupRemovals, config)
+}
+
+// checkRemovals applies the guard rails to the removals from each mapped organization, team or group,
+// a wrong or empty mapping group would otherwise empty it
+func checkRemovals(kind string, sizes map[string]MappedSize, removals map[string]int, config Config) error {
+	names := []string{}
+	for name := range removals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		removed, size := removals[name], sizes[name]
+		if size.Desired == 0 && size.Members > 0 {
+			return fmt.Errorf("%w: mapped groups of %s %s have no members but it has %d", ErrAborted, kind, name, size.Members)
+		}
+
+		if config.MaxDeletes > 0 && removed > config.MaxDeletes {
+			return fmt.Errorf("%w: %d removals from %s %s exceed the maximum of %d", ErrAborted, removed, kind, name, config.MaxDeletes)
+		}
+
+		if config.MaxDeletePercent > 0 && size.Members > 0 {
+			if removed*100 > config.MaxDeletePercent*size.Members {
+				return fmt.Errorf("%w: %d removals of %d members of %s %s exceed the maximum of %d%%",
+					ErrAborted, removed, size.Members, kind, name, config.MaxDeletePercent)
+			}
+		}
+	}
+	return nil
+}
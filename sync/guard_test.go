@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckGuardRails(t *testing.T) {
+	deletes := func(n int) []Action {
+		actions := []Action{}
+		for i := 0; i < n; i++ {
+			actions = append(actions, Action{Type: Delete, ID: string(rune('a' + i))})
+		}
+		return actions
+	}
+	removals := func(typ ActionType, org string, team string, n int) []Action {
+		actions := []Action{}
+		for i := 0; i < n; i++ {
+			actions = append(actions, Action{Type: typ, Organization: org, Team: team, Login: string(rune('a' + i))})
+		}
+		return actions
+	}
+
+	tests := []struct {
+		name    string
+		plan    Plan
+		config  Config
+		aborted bool
+	}{
+		{
+			name:   "no deletions",
+			plan:   Plan{Identities: 10, Members: 10, Actions: []Action{{Type: Invite}}},
+			config: Config{MaxDeletes: 1, MaxDeletePercent: 10},
+		},
+		{
+			name:   "deletions within the limits",
+			plan:   Plan{Identities: 9, Members: 10, Actions: deletes(1)},
+			config: Config{MaxDeletes: 1, MaxDeletePercent: 10},
+		},
+		{
+			name:    "deletions exceed the count",
+			plan:    Plan{Identities: 98, Members: 100, Actions: deletes(2)},
+			config:  Config{MaxDeletes: 1},
+			aborted: true,
+		},
+		{
+			name:    "deletions exceed the percentage",
+			plan:    Plan{Identities: 8, Members: 10, Actions: deletes(2)},
+			config:  Config{MaxDeletePercent: 10},
+			aborted: true,
+		},
+		{
+			name:    "empty source",
+			plan:    Plan{Identities: 0, Members: 10},
+			aborted: true,
+		},
+		{
+			name:   "mass delete allowed",
+			plan:   Plan{Identities: 0, Members: 10, Actions: deletes(10)},
+			config: Config{MaxDeletes: 1, MaxDeletePercent: 10, AllowMassDelete: true},
+		},
+		{
+			name:    "unmatchable identities exceed the maximum",
+			plan:    Plan{Identities: 10, Members: 10, Unmatchable: []UnmatchableIdentity{{}, {}}},
+			config:  Config{MaxUnmatchable: 1},
+			aborted: true,
+		},
+		{
+			name:    "unmatchable identities are checked with mass delete allowed",
+			plan:    Plan{Identities: 10, Members: 10, Unmatchable: []UnmatchableIdentity{{}, {}}},
+			config:  Config{MaxUnmatchable: 1, AllowMassDelete: true},
+			aborted: true,
+		},
+		{
+			name: "organization removals within the limits",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(OrgRemove, "acme", "", 1),
+				Organizations: map[string]MappedSize{"acme": {Members: 10, Desired: 9}}},
+			config: Config{MaxDeletes: 1, MaxDeletePercent: 10},
+		},
+		{
+			name: "organization removals exceed the count",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(OrgRemove, "acme", "", 2),
+				Organizations: map[string]MappedSize{"acme": {Members: 100, Desired: 98}}},
+			config:  Config{MaxDeletes: 1},
+			aborted: true,
+		},
+		{
+			name: "organization removals exceed the percentage",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(OrgRemove, "acme", "", 2),
+				Organizations: map[string]MappedSize{"acme": {Members: 10, Desired: 8}}},
+			config:  Config{MaxDeletePercent: 10},
+			aborted: true,
+		},
+		{
+			name: "empty organization mapping group",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(OrgRemove, "acme", "", 1),
+				Organizations: map[string]MappedSize{"acme": {Members: 1, Desired: 0}}},
+			aborted: true,
+		},
+		{
+			name: "team removals exceed the percentage",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(TeamRemove, "acme", "developers", 5),
+				Teams: map[string]MappedSize{"acme/developers": {Members: 10, Desired: 5}}},
+			config:  Config{MaxDeletePercent: 10},
+			aborted: true,
+		},
+		{
+			name: "empty mirrored group",
+			plan: Plan{Identities: 10, Members: 10, Actions: []Action{{Type: GroupRemove, Group: "g1", Login: "a"}},
+				Groups: map[string]MappedSize{"g1": {Members: 1, Desired: 0}}},
+			aborted: true,
+		},
+		{
+			name: "team removals allowed",
+			plan: Plan{Identities: 10, Members: 10, Actions: removals(TeamRemove, "acme", "developers", 5),
+				Teams: map[string]MappedSize{"acme/developers": {Members: 5, Desired: 0}}},
+			config: Config{MaxDeletePercent: 10, AllowMassDelete: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGuardRails(&tt.plan, tt.config)
+			if tt.aborted && !errors.Is(err, ErrAborted) {
+				t.Errorf("error = %v, want ErrAborted", err)
+			}
+			if !tt.aborted && err != nil {
+				t.Errorf("error = %v, want none", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// InactiveMember is a member without activity within the inactive days of the policy, or an identity
+// that is not invited again because it was removed for inactivity
+type InactiveMember struct {
+	Member
+	DisplayName string `json:"displayName,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// InactiveRemoval is an identity of the source whose member was removed for inactivity
+type InactiveRemoval struct {
+	ObjectId string    `json:"objectId"`
+	Login    string    `json:"login"`
+	Email    string    `json:"email"`
+	Since    time.Time `json:"since"`
+}
+
+// applyInactivity remembers the identities of inactive members that are deleted and drops the invitations
+// of identities that were removed for inactivity before. Identities that left the source or are members
+// again are forgotten.
+func applyInactivity(ctx context.Context, plan *Plan, state *State, next *State, now time.Time) {
+	actions := []Action{}
+	for _, a := range plan.Actions {
+		switch {
+		case a.Type == Delete && a.Inactive && a.ObjectId != "":
+			next.Inactive[a.ObjectId] = InactiveRemoval{ObjectId: a.ObjectId, Login: a.Login, Email: a.Email, Since: now}
+		case a.Type == Invite && a.ObjectId != "":
+			removal, ok := state.Inactive[a.ObjectId]
+			if !ok {
+				break
+			}
+			slog.DebugContext(ctx, "User removed for inactivity, not invited again", "email", a.Email, "login", removal.Login, "since", removal.Since)
+			next.Inactive[a.ObjectId] = removal
+			plan.Inactive = append(plan.Inactive, InactiveMember{
+				Member:      Member{Login: removal.Login, Email: a.Email},
+				DisplayName: a.DisplayName,
+				Reason:      "removed for inactivity at " + removal.Since.Format(time.DateOnly),
+			})
+			continue
+		}
+		actions = append(actions, a)
+	}
+	plan.Actions = actions
+}
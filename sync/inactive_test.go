@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyInactivity(t *testing.T) {
+	removal := InactiveRemoval{ObjectId: "1", Login: "alice", Email: "alice@example.com", Since: testNow.AddDate(0, 0, -30)}
+
+	tests := []struct {
+		name     string
+		actions  []Action
+		inactive map[string]InactiveRemoval
+		// want are the remaining actions, the reported logins and the object IDs of the next state
+		want         []string
+		wantInactive []string
+		wantNext     []string
+	}{
+		{
+			name:     "inactive deletion is remembered",
+			actions:  []Action{{Type: Delete, ID: "a", Login: "alice", Inactive: true, ObjectId: "1"}},
+			want:     []string{"delete:alice"},
+			wantNext: []string{"1"},
+		},
+		{
+			name:     "deletion of a member missing in the source is not remembered",
+			actions:  []Action{{Type: Delete, ID: "a", Login: "alice", ObjectId: "1"}},
+			want:     []string{"delete:alice"},
+			wantNext: []string{},
+		},
+		{
+			name:         "identity removed for inactivity is not invited",
+			actions:      []Action{{Type: Invite, Email: "alice@example.com", ObjectId: "1"}},
+			inactive:     map[string]InactiveRemoval{"1": removal},
+			want:         []string{},
+			wantInactive: []string{"alice"},
+			wantNext:     []string{"1"},
+		},
+		{
+			name:     "other identities are invited",
+			actions:  []Action{{Type: Invite, Email: "bob@example.com", ObjectId: "2"}},
+			inactive: map[string]InactiveRemoval{"1": removal},
+			want:     []string{"invite:bob@example.com"},
+			wantNext: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newState()
+			for id, r := range tt.inactive {
+				state.Inactive[id] = r
+			}
+			next := newState()
+			plan := &Plan{Actions: tt.actions}
+
+			applyInactivity(context.Background(), plan, state, next, testNow)
+
+			if got := summary(plan.Actions); !slices.Equal(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+			inactive := []string{}
+			for _, m := range plan.Inactive {
+				inactive = append(inactive, m.Login)
+			}
+			if !slices.Equal(inactive, tt.wantInactive) {
+				t.Errorf("inactive = %v, want %v", inactive, tt.wantInactive)
+			}
+			ids := []string{}
+			for id := range next.Inactive {
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.wantNext) {
+				t.Errorf("next state = %v, want %v", ids, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestNewPlanInactiveWithGracePeriod(t *testing.T) {
+	source := &fakeSource{identities: []Identity{
+		{ObjectId: "1", Email: "alice@example.com"},
+		{ObjectId: "2", Email: "bob@example.com"},
+	}}
+	// NewPlan compares the activity with the current time
+	inactive, active := time.Now().AddDate(0, 0, -100), time.Now().AddDate(0, 0, -1)
+	target := &fakeTarget{members: []Member{
+		{ID: "a", Login: "alice", Email: "alice@example.com", LastActivity: &inactive},
+		{ID: "b", Login: "bob", Email: "bob@example.com", LastActivity: &active},
+	}}
+	config := Config{
+		GracePeriod: 72 * time.Hour,
+		StateStore:  &memoryStore{},
+		Policy:      Policy{InactiveDays: 90, RemoveInactive: true},
+	}
+
+	plan, err := NewPlan(context.Background(), source, target, config)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	if got := summary(plan.Actions); len(got) != 0 {
+		t.Errorf("actions = %v, want none while the removal is pending", got)
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Login != "alice" {
+		t.Errorf("pending = %+v, want alice", plan.Pending)
+	}
+}
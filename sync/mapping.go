@@ -0,0 +1,219 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// MappedSize is the number of current and desired members of a mapped organization or team,
+// the guard rails compare the removals with it
+type MappedSize struct {
+	Members int `json:"members"`
+	Desired int `json:"desired"`
+}
+
+// mappingKind describes how the members of organizations or teams are reconciled with source groups
+type mappingKind struct {
+	// name is organization or team, used in logs and errors
+	name   string
+	add    ActionType
+	role   ActionType
+	remove ActionType
+	// higher returns the role with more permissions
+	higher func(a string, b string) string
+}
+
+// grant gives the members of a source group a role in an organization or, if team is set, in a team
+type grant struct {
+	group string
+	org   string
+	team  string
+	role  string
+}
+
+// name is the organization or organization/team
+func (g grant) name() string {
+	if g.team == "" {
+		return g.org
+	}
+	return g.org + "/" + g.team
+}
+
+// roleMember is a current member of an organization or team with its role
+type roleMember struct {
+	Member
+	role string
+}
+
+// loadMembers returns the current members of the organization or team and the actions that have
+// to run before members can be added, e.g. creating a missing team
+type loadMembers func(ctx context.Context, org string, team string) ([]roleMember, []Action, error)
+
+// reconcileMapped returns the actions that make the members and roles of the mapped organizations or
+// teams match the source groups. Members that are deleted from the enterprise leave them anyway and
+// are skipped, protected members are never removed.
+func reconcileMapped(ctx context.Context, kind mappingKind, grants []grant, groups GroupSource, load loadMembers, members []Member, deleted map[string]bool, protector *protector, config Config) ([]Action, map[string]MappedSize, error) {
+	// desired roles by name and member login
+	desired := map[string]map[string]string{}
+	reasons := map[string]map[string]string{}
+	mapped := map[string]grant{}
+	for _, g := range grants {
+		name := g.name()
+		if desired[name] == nil {
+			desired[name] = map[string]string{}
+			reasons[name] = map[string]string{}
+			mapped[name] = g
+		}
+
+		logins, err := groupLogins(ctx, groups, g.group, members, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, login := range logins {
+			// the reason is the group that grants the role, another group with the same role does not change it
+			role, ok := desired[name][login]
+			if higher := kind.higher(role, g.role); !ok || higher != role {
+				desired[name][login] = higher
+				reasons[name][login] = "in group " + g.group
+			}
+		}
+	}
+
+	byLogin := map[string]Member{}
+	for _, member := range members {
+		byLogin[strings.ToLower(member.Login)] = member
+	}
+
+	names := []string{}
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	actions := []Action{}
+	sizes := map[string]MappedSize{}
+	for _, name := range names {
+		org, team := mapped[name].org, mapped[name].team
+		current, before, err := load(ctx, org, team)
+		if err != nil {
+			return nil, nil, err
+		}
+		actions = append(actions, before...)
+		slog.InfoContext(ctx, "Checking "+kind.name, kind.name, name, "members", len(current), "desired", len(desired[name]))
+		sizes[name] = MappedSize{Members: len(current), Desired: len(desired[name])}
+
+		roles := map[string]string{}
+		for _, m := range current {
+			login := strings.ToLower(m.Login)
+			roles[login] = m.role
+			if deleted[login] {
+				continue
+			}
+			member := m.Member
+			if enterprise, ok := byLogin[login]; ok {
+				member = enterprise
+			}
+
+			role, ok := desired[name][login]
+			switch {
+			case !ok:
+				if protected, why := protector.protects(member); protected {
+					slog.InfoContext(ctx, "Member not desired but protected", kind.name, name, "login", member.Login, "reason", why)
+					continue
+				}
+				actions = append(actions, mappingAction(kind.remove, org, team, m.role, member, "not in a mapped group"))
+			case role != m.role:
+				actions = append(actions, mappingAction(kind.role, org, team, role, member, fmt.Sprintf("role %s, %s", m.role, reasons[name][login])))
+			}
+		}
+
+		logins := []string{}
+		for login := range desired[name] {
+			logins = append(logins, login)
+		}
+		sort.Strings(logins)
+		for _, login := range logins {
+			if _, ok := roles[login]; ok || deleted[login] {
+				continue
+			}
+			actions = append(actions, mappingAction(kind.add, org, team, desired[name][login], byLogin[login], reasons[name][login]))
+		}
+	}
+	return actions, sizes, nil
+}
+
+// groupLogins returns the lower case logins of the members that match a desired identity of the group
+func groupLogins(ctx context.Context, groups GroupSource, group string, members []Member, config Config) ([]string, error) {
+	identities, err := groups.GroupIdentities(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	matcher := newMatcher(config.MatchStrategies, identities)
+	logins := []string{}
+	for _, member := range members {
+		i, _, ok := matcher.match(member)
+		if !ok {
+			continue
+		}
+		if excluded, _ := config.Policy.excludes(identities[i]); excluded {
+			continue
+		}
+		logins = append(logins, strings.ToLower(member.Login))
+	}
+	return logins, nil
+}
+
+// mappingAction returns the action of the type for the member in the organization or team
+func mappingAction(t ActionType, org string, team string, role string, member Member, reason string) Action {
+	return Action{
+		Type:         t,
+		ID:           member.ID,
+		Login:        member.Login,
+		Email:        member.Email,
+		Organization: org,
+		Team:         team,
+		Role:         role,
+		Reason:       reason,
+	}
+}
+
+// executeMapped runs an organization, team or group action. doing and do describe it in the logs, e.g.
+// "Setting organization role" and "set organization role", outcome is reported if run succeeds.
+func executeMapped(ctx context.Context, a Action, config Config, doing string, do string, outcome string, run func() error) ActionResult {
+	result := ActionResult{Action: a}
+	attrs := []any{}
+	if a.Organization != "" {
+		attrs = append(attrs, "organization", a.Organization)
+	}
+	if a.GroupName != "" {
+		attrs = append(attrs, "group", a.GroupName)
+	}
+	if a.Team != "" {
+		attrs = append(attrs, "team", a.Team)
+	}
+	if a.Login != "" {
+		attrs = append(attrs, "login", a.Login)
+	}
+	if a.Type == OrgAdd || a.Type == OrgRole || a.Type == TeamAdd || a.Type == TeamRole {
+		attrs = append(attrs, "role", a.Role)
+	}
+
+	if config.DryRun {
+		slog.Info("Dry-run, would "+do, attrs...)
+		result.Outcome = "dry-run"
+		return result
+	}
+
+	slog.InfoContext(ctx, doing, attrs...)
+	err := run()
+	if err != nil {
+		slog.WarnContext(ctx, "Unable to "+do, append(attrs, "error", err)...)
+		result.Error = err.Error()
+		return result
+	}
+	result.Outcome = outcome
+	return result
+}
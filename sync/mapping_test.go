@@ -0,0 +1,206 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestReconcileOrgMappings(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	bob := Identity{ObjectId: "2", Email: "bob@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob", Email: "bob@example.com"}
+	members := []Member{aliceMember, bobMember}
+	admins := grant{group: "admins", org: "acme", role: RoleAdmin}
+	developers := grant{group: "developers", org: "acme", role: RoleMember}
+
+	tests := []struct {
+		name       string
+		grants     []grant
+		groups     fakeGroups
+		current    []roleMember
+		protection Protection
+		actions    []string
+	}{
+		{
+			name:    "group member is added",
+			grants:  []grant{developers},
+			groups:  fakeGroups{"developers": {alice, bob}},
+			current: []roleMember{{Member: aliceMember, role: RoleMember}},
+			actions: []string{"org-add:acme:bob"},
+		},
+		{
+			name:    "member missing in the groups is removed",
+			grants:  []grant{developers},
+			groups:  fakeGroups{"developers": {alice}},
+			current: []roleMember{{Member: aliceMember, role: RoleMember}, {Member: bobMember, role: RoleMember}},
+			actions: []string{"org-remove:acme:bob"},
+		},
+		{
+			name:       "protected member is not removed",
+			grants:     []grant{developers},
+			groups:     fakeGroups{"developers": {alice}},
+			current:    []roleMember{{Member: aliceMember, role: RoleMember}, {Member: bobMember, role: RoleMember}},
+			protection: Protection{Logins: []string{"bob"}},
+			actions:    []string{},
+		},
+		{
+			name:    "member of two groups gets the higher role",
+			grants:  []grant{admins, developers},
+			groups:  fakeGroups{"admins": {alice}, "developers": {alice, bob}},
+			current: []roleMember{{Member: aliceMember, role: RoleMember}, {Member: bobMember, role: RoleMember}},
+			actions: []string{"org-role:acme:alice"},
+		},
+		{
+			name:    "admin of no admin group is demoted",
+			grants:  []grant{developers},
+			groups:  fakeGroups{"developers": {alice}},
+			current: []roleMember{{Member: aliceMember, role: RoleAdmin}},
+			actions: []string{"org-role:acme:alice"},
+		},
+		{
+			name:    "member with the desired role stays",
+			grants:  []grant{admins, developers},
+			groups:  fakeGroups{"admins": {alice}, "developers": {bob}},
+			current: []roleMember{{Member: aliceMember, role: RoleAdmin}, {Member: bobMember, role: RoleMember}},
+			actions: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			load := func(ctx context.Context, org string, team string) ([]roleMember, []Action, error) {
+				return tt.current, nil, nil
+			}
+			protector := testProtector(t, &fakeTarget{members: members}, tt.protection)
+
+			actions, sizes, err := reconcileMapped(context.Background(), orgKind, tt.grants, tt.groups, load, members, map[string]bool{}, protector, Config{})
+			if err != nil {
+				t.Fatalf("reconcileMapped: %v", err)
+			}
+
+			if got := summary(actions); !slices.Equal(got, tt.actions) {
+				t.Errorf("actions = %v, want %v", got, tt.actions)
+			}
+			if sizes["acme"].Members != len(tt.current) {
+				t.Errorf("size = %+v, want %d members", sizes["acme"], len(tt.current))
+			}
+		})
+	}
+}
+
+func TestReconcileMappedReason(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "alice@example.com"}
+	grants := []grant{
+		{group: "admins", org: "acme", role: RoleAdmin},
+		{group: "developers", org: "acme", role: RoleMember},
+	}
+	load := func(ctx context.Context, org string, team string) ([]roleMember, []Action, error) {
+		return []roleMember{{Member: aliceMember, role: RoleMember}}, nil, nil
+	}
+	groups := fakeGroups{"admins": {alice}, "developers": {alice}}
+	target := &fakeTarget{members: []Member{aliceMember}}
+
+	actions, _, err := reconcileMapped(context.Background(), orgKind, grants, groups, load, target.members, map[string]bool{}, testProtector(t, target, Protection{}), Config{})
+	if err != nil {
+		t.Fatalf("reconcileMapped: %v", err)
+	}
+
+	if len(actions) != 1 || actions[0].Reason != "role member, in group admins" {
+		t.Errorf("actions = %+v, want the role change in group admins", actions)
+	}
+}
+
+func TestReconcileTeams(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	bob := Identity{ObjectId: "2", Email: "bob@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob", Email: "bob@example.com"}
+	groups := fakeGroups{"leads": {alice}, "developers": {alice, bob}}
+	mappings := []TeamMapping{
+		{Group: "leads", Organization: "acme", Team: "backend", Role: RoleMaintainer},
+		{Group: "developers", Organization: "acme", Team: "backend", Role: RoleMember},
+	}
+
+	tests := []struct {
+		name    string
+		teams   map[string][]TeamMember
+		create  bool
+		actions []string
+		err     bool
+	}{
+		{
+			name:    "members are added with the higher role",
+			teams:   map[string][]TeamMember{"acme/backend": {}},
+			actions: []string{"team-add:acme/backend:alice", "team-add:acme/backend:bob"},
+		},
+		{
+			name:    "maintainer of no maintainer group is demoted and other members are removed",
+			teams:   map[string][]TeamMember{"acme/backend": {{Member: aliceMember, Role: RoleMaintainer}, {Member: bobMember, Role: RoleMaintainer}, {Member: Member{ID: "c", Login: "carol"}, Role: RoleMember}}},
+			actions: []string{"team-role:acme/backend:bob", "team-remove:acme/backend:carol"},
+		},
+		{
+			name:    "missing team is created before members are added",
+			teams:   map[string][]TeamMember{},
+			create:  true,
+			actions: []string{"team-create:acme/backend", "team-add:acme/backend:alice", "team-add:acme/backend:bob"},
+		},
+		{
+			name:  "missing team is an error without create",
+			teams: map[string][]TeamMember{},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTeams{fakeTarget: fakeTarget{members: []Member{aliceMember, bobMember}}, teams: tt.teams}
+			config := Config{TeamMappings: mappings, CreateTeams: tt.create}
+
+			actions, _, err := reconcileTeams(context.Background(), groups, target, target.members, map[string]bool{}, testProtector(t, target, Protection{}), config)
+			if tt.err {
+				if err == nil {
+					t.Errorf("actions = %v, want an error", summary(actions))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reconcileTeams: %v", err)
+			}
+
+			if got := summary(actions); !slices.Equal(got, tt.actions) {
+				t.Errorf("actions = %v, want %v", got, tt.actions)
+			}
+			for _, a := range actions {
+				if a.Login == "alice" && a.Role != RoleMaintainer {
+					t.Errorf("alice role = %s, want %s", a.Role, RoleMaintainer)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileMappedSkipsDeleted(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	bob := Identity{ObjectId: "2", Email: "bob@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob", Email: "bob@example.com"}
+	grants := []grant{{group: "developers", org: "acme", role: RoleMember}}
+	load := func(ctx context.Context, org string, team string) ([]roleMember, []Action, error) {
+		return []roleMember{{Member: aliceMember, role: RoleAdmin}}, nil, nil
+	}
+	target := &fakeTarget{members: []Member{aliceMember, bobMember}}
+	// inactive members stay in the groups but are deleted or pending removal
+	deleted := map[string]bool{"alice": true, "bob": true}
+
+	actions, _, err := reconcileMapped(context.Background(), orgKind, grants, fakeGroups{"developers": {alice, bob}}, load, target.members, deleted, testProtector(t, target, Protection{}), Config{})
+	if err != nil {
+		t.Fatalf("reconcileMapped: %v", err)
+	}
+
+	if got := summary(actions); len(got) != 0 {
+		t.Errorf("actions = %v, want none for deleted members", got)
+	}
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type MatchStrategy string
+
+const (
+	// MatchMail matches the mail of the identity
+	MatchMail MatchStrategy = "mail"
+	// MatchUPN matches the user principal name of the identity
+	MatchUPN MatchStrategy = "upn"
+	// MatchAddresses matches any proxy address or other mail of the identity
+	MatchAddresses MatchStrategy = "addresses"
+	// MatchEmployeeId matches the employee ID of the identity
+	MatchEmployeeId MatchStrategy = "employeeId"
+	// MatchObjectId matches the object ID of the identity
+	MatchObjectId MatchStrategy = "objectId"
+)
+
+// ParseMatchStrategy returns the strategy with the name
+func ParseMatchStrategy(name string) (MatchStrategy, error) {
+	for _, strategy := range []MatchStrategy{MatchMail, MatchUPN, MatchAddresses, MatchEmployeeId, MatchObjectId} {
+		if strings.EqualFold(name, string(strategy)) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown match strategy %s", name)
+}
+
+// Match is a member that matched a desired identity
+type Match struct {
+	Member
+	DisplayName string        `json:"displayName,omitempty"`
+	MatchedBy   MatchStrategy `json:"matchedBy"`
+	Groups      []string      `json:"groups,omitempty"`
+}
+
+// UnmatchableIdentity is an identity of the source that can neither be matched nor invited
+type UnmatchableIdentity struct {
+	ObjectId          string   `json:"objectId,omitempty"`
+	DisplayName       string   `json:"displayName,omitempty"`
+	UserPrincipalName string   `json:"userPrincipalName,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
+	Reason            string   `json:"reason"`
+}
+
+// keys returns the lower case values of the identity used by the strategy
+func (s MatchStrategy) keys(identity Identity) []string {
+	values := []string{}
+	switch s {
+	case MatchMail:
+		values = append(values, identity.Email)
+	case MatchUPN:
+		values = append(values, identity.UserPrincipalName)
+	case MatchAddresses:
+		values = append(values, identity.Addresses...)
+	case MatchEmployeeId:
+		values = append(values, identity.EmployeeId)
+	case MatchObjectId:
+		values = append(values, identity.ObjectId)
+	}
+
+	keys := []string{}
+	for _, value := range values {
+		if value != "" {
+			keys = append(keys, strings.ToLower(value))
+		}
+	}
+	return keys
+}
+
+// matcher finds the identity of a member by trying the strategies in order
+type matcher struct {
+	strategies []MatchStrategy
+	indexes    map[MatchStrategy]map[string]int
+}
+
+func newMatcher(strategies []MatchStrategy, identities []Identity) *matcher {
+	if len(strategies) == 0 {
+		strategies = []MatchStrategy{MatchMail}
+	}
+
+	m := matcher{
+		strategies: strategies,
+		indexes:    map[MatchStrategy]map[string]int{},
+	}
+	for _, strategy := range strategies {
+		index := map[string]int{}
+		for i, identity := range identities {
+			for _, key := range strategy.keys(identity) {
+				if j, ok := index[key]; ok && j != i {
+					slog.Warn("Ambiguous match key, using first identity",
+						"strategy", strategy,
+						"key", key,
+						"first", identities[j].DisplayName,
+						"second", identity.DisplayName)
+					continue
+				}
+				index[key] = i
+			}
+		}
+		m.indexes[strategy] = index
+	}
+	return &m
+}
+
+// matchable returns if any strategy has a key for the identity
+func (m *matcher) matchable(identity Identity) bool {
+	for _, strategy := range m.strategies {
+		if len(strategy.keys(identity)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the index of the identity matching the member and the strategy that matched.
+// The SAML name ID and the SCIM user name of the member are used as keys.
+func (m *matcher) match(member Member) (int, MatchStrategy, bool) {
+	for _, strategy := range m.strategies {
+		for _, key := range []string{member.Email, member.ScimUsername} {
+			if key == "" {
+				continue
+			}
+			if i, ok := m.indexes[strategy][strings.ToLower(key)]; ok {
+				return i, strategy, true
+			}
+		}
+	}
+	return -1, "", false
+}
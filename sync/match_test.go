@@ -0,0 +1,62 @@
+package sync
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	identities := []Identity{
+		{ObjectId: "1", Email: "jane@example.com", UserPrincipalName: "jane.doe@corp.example.com", Addresses: []string{"j.doe@example.com"}, EmployeeId: "E1"},
+		{ObjectId: "2", Email: "john@example.com", UserPrincipalName: "john@corp.example.com"},
+		// same mail as the first identity, the first one wins
+		{ObjectId: "3", Email: "Jane@example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		strategies []MatchStrategy
+		member     Member
+		want       int
+		matchedBy  MatchStrategy
+		ok         bool
+	}{
+		{name: "mail is the default", member: Member{Email: "JANE@example.com"}, want: 0, matchedBy: MatchMail, ok: true},
+		{name: "upn", strategies: []MatchStrategy{MatchUPN}, member: Member{Email: "john@corp.example.com"}, want: 1, matchedBy: MatchUPN, ok: true},
+		{name: "addresses", strategies: []MatchStrategy{MatchAddresses}, member: Member{Email: "j.doe@example.com"}, want: 0, matchedBy: MatchAddresses, ok: true},
+		{name: "employee ID", strategies: []MatchStrategy{MatchEmployeeId}, member: Member{Email: "e1"}, want: 0, matchedBy: MatchEmployeeId, ok: true},
+		{name: "object ID of the SCIM user name", strategies: []MatchStrategy{MatchObjectId}, member: Member{ScimUsername: "2"}, want: 1, matchedBy: MatchObjectId, ok: true},
+		{name: "strategies are tried in order", strategies: []MatchStrategy{MatchUPN, MatchMail}, member: Member{Email: "john@example.com"}, want: 1, matchedBy: MatchMail, ok: true},
+		{name: "unused strategy does not match", strategies: []MatchStrategy{MatchMail}, member: Member{Email: "jane.doe@corp.example.com"}, want: -1},
+		{name: "ambiguous key matches the first identity", member: Member{Email: "jane@example.com"}, want: 0, matchedBy: MatchMail, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMatcher(tt.strategies, identities)
+
+			i, matchedBy, ok := m.match(tt.member)
+			if i != tt.want || matchedBy != tt.matchedBy || ok != tt.ok {
+				t.Errorf("match = %d, %q, %t, want %d, %q, %t", i, matchedBy, ok, tt.want, tt.matchedBy, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMatcherMatchable(t *testing.T) {
+	m := newMatcher([]MatchStrategy{MatchMail, MatchEmployeeId}, nil)
+
+	if !m.matchable(Identity{EmployeeId: "E1"}) {
+		t.Error("identity with an employee ID is not matchable")
+	}
+	if m.matchable(Identity{UserPrincipalName: "jane@example.com"}) {
+		t.Error("identity without mail and employee ID is matchable")
+	}
+}
+
+func TestParseMatchStrategy(t *testing.T) {
+	strategy, err := ParseMatchStrategy("EmployeeID")
+	if err != nil || strategy != MatchEmployeeId {
+		t.Errorf("strategy = %q, error = %v, want %q", strategy, err, MatchEmployeeId)
+	}
+	if _, err := ParseMatchStrategy("login"); err == nil {
+		t.Error("unknown strategy is accepted")
+	}
+}
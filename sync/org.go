@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	// RoleMember is the role of a regular organization member
+	RoleMember = "member"
+	// RoleAdmin is the role of an organization owner
+	RoleAdmin = "admin"
+)
+
+// OrgMapping grants the members of a source group a role in an organization
+type OrgMapping struct {
+	Group        string
+	Organization string
+	Role         string
+}
+
+// OrgMember is a member of an organization with its role
+type OrgMember struct {
+	Member
+	Role string
+}
+
+// GroupSource provides the desired identities of a single group of the source
+type GroupSource interface {
+	GroupIdentities(ctx context.Context, group string) ([]Identity, error)
+}
+
+// OrgTarget manages the membership and role in the organizations of the target
+type OrgTarget interface {
+	OrgMembers(ctx context.Context, org string) ([]OrgMember, error)
+	SetOrgRole(ctx context.Context, org string, member Member, role string) error
+	RemoveOrgMember(ctx context.Context, org string, member Member) error
+}
+
+// higherRole returns the role with more permissions
+func higherRole(a string, b string) string {
+	if a == RoleAdmin || b == RoleAdmin {
+		return RoleAdmin
+	}
+	return RoleMember
+}
+
+// orgKind reconciles the members and roles of organizations
+var orgKind = mappingKind{name: "organization", add: OrgAdd, role: OrgRole, remove: OrgRemove, higher: higherRole}
+
+// reconcileOrgs returns the actions that make the members and roles of the mapped organizations match
+// the source groups. Only members of the enterprise can be added, the others are invited first.
+func reconcileOrgs(ctx context.Context, source IdentitySource, target MembershipTarget, members []Member, deleted map[string]bool, protector *protector, config Config) ([]Action, map[string]MappedSize, error) {
+	groups, ok := source.(GroupSource)
+	if !ok {
+		return nil, nil, errors.New("source does not support organization mappings")
+	}
+	orgs, ok := target.(OrgTarget)
+	if !ok {
+		return nil, nil, errors.New("target does not support organization mappings")
+	}
+
+	grants := []grant{}
+	for _, mapping := range config.OrgMappings {
+		grants = append(grants, grant{group: mapping.Group, org: mapping.Organization, role: mapping.Role})
+	}
+	load := func(ctx context.Context, org string, _ string) ([]roleMember, []Action, error) {
+		current, err := orgs.OrgMembers(ctx, org)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error loading members of organization %s: %w", org, err)
+		}
+		list := []roleMember{}
+		for _, m := range current {
+			list = append(list, roleMember{Member: m.Member, role: m.Role})
+		}
+		return list, nil, nil
+	}
+	return reconcileMapped(ctx, orgKind, grants, groups, load, members, deleted, protector, config)
+}
+
+// orgRoleAction adds the member of the action to the organization or changes its role
+func orgRoleAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Setting organization role", "set organization role", a.Role, func() error {
+		orgs, ok := target.(OrgTarget)
+		if !ok {
+			return errors.New("target does not support organization mappings")
+		}
+		return orgs.SetOrgRole(ctx, a.Organization, a.member(), a.Role)
+	})
+}
+
+// orgRemoveAction removes the member of the action from the organization
+func orgRemoveAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Removing from organization", "remove from organization", "removed", func() error {
+		orgs, ok := target.(OrgTarget)
+		if !ok {
+			return errors.New("target does not support organization mappings")
+		}
+		return orgs.RemoveOrgMember(ctx, a.Organization, a.member())
+	})
+}
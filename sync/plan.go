@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// PlanVersion is the version of the plan file format
+const PlanVersion = 1
+
+// Plan is the reviewable list of actions computed by a sync
+type Plan struct {
+	Version    int               `json:"version"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Identities int               `json:"identities"`
+	Members    int               `json:"members"`
+	Stay       int               `json:"stay"`
+	Actions    []Action          `json:"actions"`
+	Matched    []Match           `json:"matched"`
+	Protected  []ProtectedMember `json:"protected"`
+	// Unmatchable are identities of the source that can neither be matched nor invited
+	Unmatchable []UnmatchableIdentity `json:"unmatchable"`
+	// Pending are members missing in the source whose removal is delayed by the grace period
+	Pending []PendingRemoval `json:"pending"`
+	// Inactive are members without recent activity and identities not invited again for inactivity
+	Inactive []InactiveMember `json:"inactive"`
+	// Organizations are the sizes of the mapped organizations by name
+	Organizations map[string]MappedSize `json:"organizations"`
+	// Teams are the sizes of the mapped teams by organization/team
+	Teams map[string]MappedSize `json:"teams"`
+	// Groups are the sizes of the mirrored groups by source group ID
+	Groups map[string]MappedSize `json:"groups,omitempty"`
+	// state is saved after the actions are executed, nil without grace period and removal of inactive members
+	state *State
+	// previous is the state read before the plan was computed, an unchanged state is not saved again
+	previous *State
+}
+
+// NewPlan loads the identities and members and computes the actions
+func NewPlan(ctx context.Context, source IdentitySource, target MembershipTarget, config Config) (*Plan, error) {
+	members, err := target.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := source.Identities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	protector, err := newProtector(ctx, target, config.Protection)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := newMatcher(config.MatchStrategies, identities)
+	_, updates := target.(Updater)
+	now := time.Now().UTC()
+	plan := reconcile(ctx, identities, members, matcher, protector, config.Policy, updates, now)
+	if config.GracePeriod > 0 || config.Policy.RemoveInactive {
+		state, err := ReadState(ctx, config.StateStore)
+		if err != nil {
+			return nil, err
+		}
+		plan.previous = state
+		plan.state = newState()
+		if config.GracePeriod > 0 {
+			applyGracePeriod(ctx, plan, state, plan.state, config.GracePeriod, now)
+		}
+		if config.Policy.RemoveInactive {
+			applyInactivity(ctx, plan, state, plan.state, now)
+		}
+	}
+
+	// members that are deleted or pending removal keep their organization, team and group memberships
+	deleted := map[string]bool{}
+	for _, a := range plan.Actions {
+		if a.Type == Delete {
+			deleted[strings.ToLower(a.Login)] = true
+		}
+	}
+	for _, p := range plan.Pending {
+		deleted[strings.ToLower(p.Login)] = true
+	}
+	if len(config.OrgMappings) > 0 {
+		actions, sizes, err := reconcileOrgs(ctx, source, target, members, deleted, protector, config)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, actions...)
+		plan.Organizations = sizes
+	}
+	if len(config.TeamMappings) > 0 {
+		actions, sizes, err := reconcileTeams(ctx, source, target, members, deleted, protector, config)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, actions...)
+		plan.Teams = sizes
+	}
+	if len(config.Groups) > 0 {
+		actions, sizes, err := reconcileGroups(ctx, source, target, members, deleted, protector, config)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, actions...)
+		plan.Groups = sizes
+	}
+	plan.Version = PlanVersion
+	plan.CreatedAt = now
+	return plan, nil
+}
+
+// CheckPlan applies the guard rails to the plan, the error wraps ErrAborted like the one of Sync
+func CheckPlan(plan *Plan, config Config) error {
+	return checkGuardRails(plan, config)
+}
+
+// WritePlan writes the plan as JSON to the file
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing plan %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadPlan reads a plan written by WritePlan
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan %s: %w", path, err)
+	}
+
+	plan := Plan{}
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding plan %s: %w", path, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d in %s", plan.Version, path)
+	}
+	return &plan, nil
+}
+
+// Apply executes exactly the actions of the plan. Every action is validated against the current
+// state first, actions that are no longer necessary are skipped.
+func Apply(ctx context.Context, source IdentitySource, target MembershipTarget, plan *Plan, config Config) (*Report, error) {
+	slog.InfoContext(ctx, "Applying plan", "createdAt", plan.CreatedAt, "actions", len(plan.Actions))
+
+	current, err := NewPlan(ctx, source, target, config)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := map[string]bool{}
+	for _, a := range current.Actions {
+		valid[a.key()] = true
+	}
+
+	actions := []Action{}
+	for _, a := range plan.Actions {
+		if !valid[a.key()] {
+			slog.WarnContext(ctx, "Skipping action that is no longer valid",
+				"type", a.Type,
+				"login", a.Login,
+				"email", a.Email)
+			continue
+		}
+		actions = append(actions, a)
+	}
+
+	if len(actions) != len(plan.Actions) {
+		slog.WarnContext(ctx, "Plan is outdated", "planned", len(plan.Actions), "valid", len(actions))
+	}
+
+	return execute(ctx, target, &Plan{
+		Version:       plan.Version,
+		CreatedAt:     plan.CreatedAt,
+		Identities:    current.Identities,
+		Members:       current.Members,
+		Stay:          current.Stay,
+		Actions:       actions,
+		Matched:       current.Matched,
+		Protected:     current.Protected,
+		Unmatchable:   current.Unmatchable,
+		Pending:       current.Pending,
+		Inactive:      current.Inactive,
+		Organizations: current.Organizations,
+		Teams:         current.Teams,
+		Groups:        current.Groups,
+		state:         current.state,
+		previous:      current.previous,
+	}, config)
+}
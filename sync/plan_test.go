@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	carol := Identity{ObjectId: "3", Email: "carol@example.com"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob", Email: "bob@example.com"}
+	carolMember := Member{ID: "c", Login: "carol", Email: "carol@example.com"}
+	planned := []Action{
+		{Type: Invite, Email: "carol@example.com", ObjectId: "3"},
+		{Type: Delete, ID: "b", Login: "bob", Email: "bob@example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		members []Member
+		invited []string
+		removed []string
+	}{
+		{
+			name:    "valid actions are executed",
+			members: []Member{aliceMember, bobMember},
+			invited: []string{"carol@example.com"},
+			removed: []string{"bob"},
+		},
+		{
+			name:    "invitation of a user that joined meanwhile is skipped",
+			members: []Member{aliceMember, bobMember, carolMember},
+			removed: []string{"bob"},
+		},
+		{
+			name:    "deletion of a user that left meanwhile is skipped",
+			members: []Member{aliceMember},
+			invited: []string{"carol@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{identities: []Identity{alice, carol}}
+			target := &fakeTarget{members: tt.members}
+			plan := &Plan{Version: PlanVersion, Actions: planned}
+
+			_, err := Apply(context.Background(), source, target, plan, Config{AllowMassDelete: true})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			invited := []string{}
+			for _, i := range target.invited {
+				invited = append(invited, i.Email)
+			}
+			removed := []string{}
+			for _, m := range target.removed {
+				removed = append(removed, m.Login)
+			}
+			if !slices.Equal(invited, tt.invited) {
+				t.Errorf("invited = %v, want %v", invited, tt.invited)
+			}
+			if !slices.Equal(removed, tt.removed) {
+				t.Errorf("removed = %v, want %v", removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestApplyDoesNotExecuteNewActions(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	target := &fakeTarget{members: []Member{{ID: "b", Login: "bob", Email: "bob@example.com"}}}
+	// the plan was created before bob left the source and alice joined it
+	plan := &Plan{Version: PlanVersion, Actions: []Action{}}
+
+	_, err := Apply(context.Background(), &fakeSource{identities: []Identity{alice}}, target, plan, Config{AllowMassDelete: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if len(target.invited) != 0 || len(target.removed) != 0 {
+		t.Errorf("invited = %v, removed = %v, want only the planned actions", target.invited, target.removed)
+	}
+}
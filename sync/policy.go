@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy configures which identities of the source are not desired even though they are in the source
+type Policy struct {
+	// RemoveDisabled removes identities whose account is disabled in the source
+	RemoveDisabled bool
+	// RemoveGuests removes identities that are guests in the source
+	RemoveGuests bool
+	// InactiveDays reports members without activity within the days, 0 disables the check
+	InactiveDays int
+	// RemoveInactive removes inactive members instead of only reporting them, they are not invited again
+	RemoveInactive bool
+}
+
+// excludes returns if the identity is not desired by the policy and the reason
+func (p Policy) excludes(identity Identity) (bool, string) {
+	if p.RemoveDisabled && identity.Disabled {
+		return true, "account disabled"
+	}
+	if p.RemoveGuests && identity.Guest {
+		return true, "guest account"
+	}
+	return false, ""
+}
+
+// inactive returns if the member had no activity within the inactive days and the reason
+func (p Policy) inactive(member Member, now time.Time) (bool, string) {
+	if p.InactiveDays == 0 {
+		return false, ""
+	}
+	days := time.Duration(p.InactiveDays) * 24 * time.Hour
+	if member.LastActivity == nil {
+		// members that joined within the days or whose join date is unknown may just not have been active yet
+		if member.JoinedAt == nil || now.Sub(*member.JoinedAt) <= days {
+			return false, ""
+		}
+		return true, fmt.Sprintf("no activity in %d days", p.InactiveDays)
+	}
+	if now.Sub(*member.LastActivity) > days {
+		return true, "inactive since " + member.LastActivity.Format(time.DateOnly)
+	}
+	return false, ""
+}
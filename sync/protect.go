@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// OwnerLister is implemented by targets that can list their owners
+type OwnerLister interface {
+	Owners(ctx context.Context) ([]Member, error)
+}
+
+// Protection configures members that are never deleted
+type Protection struct {
+	Logins []string
+	Emails []string
+	// Patterns are matched against the login and the email
+	Patterns []*regexp.Regexp
+	// Owners protects all owners of the target
+	Owners bool
+}
+
+// ProtectedMember is a member that is not in the source but protected from deletion
+type ProtectedMember struct {
+	Member
+	Reason string `json:"reason"`
+}
+
+type protector struct {
+	logins   map[string]bool
+	emails   map[string]bool
+	patterns []*regexp.Regexp
+	owners   map[string]bool
+}
+
+func newProtector(ctx context.Context, target MembershipTarget, protection Protection) (*protector, error) {
+	p := protector{
+		logins:   map[string]bool{},
+		emails:   map[string]bool{},
+		patterns: protection.Patterns,
+		owners:   map[string]bool{},
+	}
+	for _, login := range protection.Logins {
+		p.logins[strings.ToLower(login)] = true
+	}
+	for _, email := range protection.Emails {
+		p.emails[strings.ToLower(email)] = true
+	}
+
+	if protection.Owners {
+		lister, ok := target.(OwnerLister)
+		if !ok {
+			return nil, errors.New("target is not able to list its owners")
+		}
+		owners, err := lister.Owners(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, owner := range owners {
+			p.owners[owner.ID] = true
+		}
+		slog.InfoContext(ctx, "Protecting owners", "count", len(owners))
+	}
+
+	return &p, nil
+}
+
+// protects returns if the member is protected and the reason
+func (p *protector) protects(member Member) (bool, string) {
+	if p.logins[strings.ToLower(member.Login)] {
+		return true, "protected login"
+	}
+	if member.Email != "" && p.emails[strings.ToLower(member.Email)] {
+		return true, "protected email"
+	}
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(member.Login) || (member.Email != "" && pattern.MatchString(member.Email)) {
+			return true, "protected pattern " + pattern.String()
+		}
+	}
+	if p.owners[member.ID] {
+		return true, "owner"
+	}
+	return false, ""
+}
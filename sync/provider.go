@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"context"
+	"time"
+)
+
+// Identity is a user that should be member of the target
+type Identity struct {
+	ObjectId          string
+	Email             string
+	DisplayName       string
+	UserPrincipalName string
+	GivenName         string
+	Surname           string
+	// Addresses are all further email addresses of the identity
+	Addresses  []string
+	EmployeeId string
+	// Groups are the source groups that granted the access
+	Groups []string
+	// Disabled is set if the account is disabled in the source
+	Disabled bool
+	// Guest is set if the account is a guest in the source
+	Guest bool
+}
+
+// Member is a user that currently is member of the target
+type Member struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+	// Email is the SAML name ID of the member
+	Email        string `json:"email"`
+	ScimUsername string `json:"scimUsername,omitempty"`
+	// DisplayName is only known by targets that manage the attributes of their members
+	DisplayName string `json:"displayName,omitempty"`
+	// LastActivity is the last contribution or login, only known by targets that load the activity
+	LastActivity *time.Time `json:"lastActivity,omitempty"`
+	// JoinedAt is when the member joined the target, only known by targets that load the activity
+	JoinedAt *time.Time `json:"joinedAt,omitempty"`
+}
+
+// IdentitySource provides the desired identities, e.g. the members of an Azure group
+type IdentitySource interface {
+	Identities(ctx context.Context) ([]Identity, error)
+}
+
+// MembershipTarget provides the current members and manages the membership, e.g. a GitHub enterprise
+type MembershipTarget interface {
+	Members(ctx context.Context) ([]Member, error)
+	// Invite returns no results if there is nothing to invite the identity into
+	Invite(ctx context.Context, identity Identity) ([]InviteResult, error)
+	Remove(ctx context.Context, member Member) error
+}
+
+// Updater is implemented by targets that manage the attributes of their members, e.g. SCIM.
+// Matched members whose display name or email differ from the identity are updated.
+type Updater interface {
+	Update(ctx context.Context, member Member, identity Identity) error
+}
+
+type InviteStatus int
+
+const (
+	// InviteSent represents an invitation that was created by this run
+	InviteSent InviteStatus = iota
+	// InvitePending represents an invitation that already existed before this run
+	InvitePending InviteStatus = iota
+	// InviteFailed represents an invitation that could not be created
+	InviteFailed InviteStatus = iota
+)
+
+func (s InviteStatus) String() string {
+	switch s {
+	case InviteSent:
+		return "sent"
+	case InvitePending:
+		return "pending"
+	case InviteFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// InviteResult is the outcome of inviting one identity into one part of the target, e.g. an organization
+type InviteResult struct {
+	Organization string
+	Email        string
+	Status       InviteStatus
+	Reason       string
+}
@@ -0,0 +1,191 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+)
+
+type Classification string
+
+const (
+	ClassStay        Classification = "stay"
+	ClassInvite      Classification = "invite"
+	ClassDelete      Classification = "delete"
+	ClassUpdate      Classification = "update"
+	ClassOrgAdd      Classification = "org-add"
+	ClassOrgRemove   Classification = "org-remove"
+	ClassOrgRole     Classification = "org-role"
+	ClassTeamAdd     Classification = "team-add"
+	ClassTeamRemove  Classification = "team-remove"
+	ClassTeamRole    Classification = "team-role"
+	ClassTeamCreate  Classification = "team-create"
+	ClassGroupAdd    Classification = "group-add"
+	ClassGroupRemove Classification = "group-remove"
+	ClassGroupCreate Classification = "group-create"
+	ClassProtected   Classification = "protected"
+	ClassPending     Classification = "pending"
+	ClassInactive    Classification = "inactive"
+	ClassUnmatched   Classification = "unmatched"
+	// ClassDryRun, ClassSkipped and ClassFailed are actions that did not change anything
+	ClassDryRun  Classification = "dry-run"
+	ClassSkipped Classification = "skipped"
+	ClassFailed  Classification = "failed"
+)
+
+// ActionResult is the outcome of executing one action
+type ActionResult struct {
+	Action  Action
+	Outcome string
+	Error   string
+	// Skipped is set if the action was not executed, e.g. after a failure with fail fast
+	Skipped bool
+}
+
+// Report lists every evaluated identity with its classification and the outcome of its action
+type Report struct {
+	CreatedAt time.Time      `json:"createdAt"`
+	DryRun    bool           `json:"dryRun"`
+	Summary   map[string]int `json:"summary"`
+	Entries   []ReportEntry  `json:"entries"`
+}
+
+// ReportEntry is one evaluated identity
+type ReportEntry struct {
+	Classification Classification `json:"classification"`
+	Action         string         `json:"action,omitempty"`
+	Login          string         `json:"login,omitempty"`
+	ID             string         `json:"id,omitempty"`
+	Organization   string         `json:"organization,omitempty"`
+	Team           string         `json:"team,omitempty"`
+	Group          string         `json:"group,omitempty"`
+	Email          string         `json:"email,omitempty"`
+	DisplayName    string         `json:"displayName,omitempty"`
+	MatchedBy      MatchStrategy  `json:"matchedBy,omitempty"`
+	Groups         []string       `json:"groups,omitempty"`
+	Reason         string         `json:"reason,omitempty"`
+	Outcome        string         `json:"outcome,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+func newReport(plan *Plan, results []ActionResult, config Config) *Report {
+	report := Report{
+		CreatedAt: time.Now().UTC(),
+		DryRun:    config.DryRun,
+		Summary:   map[string]int{},
+		Entries:   []ReportEntry{},
+	}
+
+	for _, m := range plan.Matched {
+		report.add(ReportEntry{
+			Classification: ClassStay,
+			Login:          m.Login,
+			ID:             m.ID,
+			Email:          m.Email,
+			DisplayName:    m.DisplayName,
+			MatchedBy:      m.MatchedBy,
+			Groups:         m.Groups,
+		})
+	}
+
+	for _, p := range plan.Protected {
+		report.add(ReportEntry{
+			Classification: ClassProtected,
+			Login:          p.Login,
+			ID:             p.ID,
+			Email:          p.Email,
+			Reason:         p.Reason,
+		})
+	}
+
+	for _, p := range plan.Pending {
+		report.add(ReportEntry{
+			Classification: ClassPending,
+			Login:          p.Login,
+			ID:             p.ID,
+			Email:          p.Email,
+			Reason:         fmt.Sprintf("%s since %s, removal after %s", p.Reason, p.Since.Format(time.RFC3339), p.Since.Add(config.GracePeriod).Format(time.RFC3339)),
+		})
+	}
+
+	for _, i := range plan.Inactive {
+		report.add(ReportEntry{
+			Classification: ClassInactive,
+			Login:          i.Login,
+			ID:             i.ID,
+			Email:          i.Email,
+			DisplayName:    i.DisplayName,
+			Reason:         i.Reason,
+		})
+	}
+
+	for _, u := range plan.Unmatchable {
+		report.add(ReportEntry{
+			Classification: ClassUnmatched,
+			ID:             u.ObjectId,
+			DisplayName:    u.DisplayName,
+			Groups:         u.Groups,
+			Reason:         u.Reason,
+		})
+	}
+
+	for _, r := range results {
+		classification := ClassInvite
+		switch r.Action.Type {
+		case Delete:
+			classification = ClassDelete
+		case Update:
+			classification = ClassUpdate
+		case OrgAdd:
+			classification = ClassOrgAdd
+		case OrgRemove:
+			classification = ClassOrgRemove
+		case OrgRole:
+			classification = ClassOrgRole
+		case TeamAdd:
+			classification = ClassTeamAdd
+		case TeamRemove:
+			classification = ClassTeamRemove
+		case TeamRole:
+			classification = ClassTeamRole
+		case TeamCreate:
+			classification = ClassTeamCreate
+		case GroupAdd:
+			classification = ClassGroupAdd
+		case GroupRemove:
+			classification = ClassGroupRemove
+		case GroupCreate:
+			classification = ClassGroupCreate
+		}
+		// only executed actions count as invited, removed or changed
+		switch {
+		case r.Error != "":
+			classification = ClassFailed
+		case r.Skipped:
+			classification = ClassSkipped
+		case config.DryRun:
+			classification = ClassDryRun
+		}
+		report.add(ReportEntry{
+			Classification: classification,
+			Action:         r.Action.Type.String(),
+			Login:          r.Action.Login,
+			ID:             r.Action.ID,
+			Organization:   r.Action.Organization,
+			Team:           r.Action.Team,
+			Group:          r.Action.GroupName,
+			Email:          r.Action.Email,
+			DisplayName:    r.Action.DisplayName,
+			Groups:         r.Action.Groups,
+			Reason:         r.Action.Reason,
+			Outcome:        r.Outcome,
+			Error:          r.Error,
+		})
+	}
+
+	return &report
+}
+
+func (r *Report) add(entry ReportEntry) {
+	r.Entries = append(r.Entries, entry)
+	r.Summary[string(entry.Classification)]++
+}
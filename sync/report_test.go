@@ -0,0 +1,39 @@
+package sync
+
+import "testing"
+
+func TestNewReportClassification(t *testing.T) {
+	invite := Action{Type: Invite, Email: "alice@example.com"}
+	remove := Action{Type: Delete, ID: "b", Login: "bob"}
+
+	tests := []struct {
+		name   string
+		result ActionResult
+		dryRun bool
+		want   Classification
+	}{
+		{name: "executed invitation", result: ActionResult{Action: invite, Outcome: "org: sent"}, want: ClassInvite},
+		{name: "executed deletion", result: ActionResult{Action: remove, Outcome: "removed"}, want: ClassDelete},
+		{name: "failed deletion", result: ActionResult{Action: remove, Error: "not found"}, want: ClassFailed},
+		{name: "dry-run deletion", result: ActionResult{Action: remove, Outcome: "dry-run"}, dryRun: true, want: ClassDryRun},
+		{name: "invitation without organization", result: ActionResult{Action: invite, Outcome: "skipped, nothing to invite into", Skipped: true}, want: ClassSkipped},
+		{name: "deletion after a failure", result: ActionResult{Action: remove, Outcome: "skipped after failure", Skipped: true}, want: ClassSkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newReport(&Plan{}, []ActionResult{tt.result}, Config{DryRun: tt.dryRun})
+
+			if len(report.Entries) != 1 {
+				t.Fatalf("entries = %+v, want one", report.Entries)
+			}
+			e := report.Entries[0]
+			if e.Classification != tt.want || e.Action != tt.result.Action.Type.String() {
+				t.Errorf("classification = %s, action = %s, want %s of %s", e.Classification, e.Action, tt.want, tt.result.Action.Type)
+			}
+			if report.Summary[string(tt.want)] != 1 {
+				t.Errorf("summary = %v, want one %s", report.Summary, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot file format
+const SnapshotVersion = 1
+
+// Snapshot is the access of a member captured before it is deleted, restore uses it to grant the access again
+type Snapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Member    Member    `json:"member"`
+	// UserId is the numeric ID of the user, required to invite it without email
+	UserId        int64                  `json:"userId"`
+	Organizations []OrgMembership        `json:"organizations"`
+	Teams         []TeamMembership       `json:"teams"`
+	Collaborators []RepositoryPermission `json:"collaborators"`
+}
+
+// OrgMembership is the role of the member in an organization
+type OrgMembership struct {
+	Organization string `json:"organization"`
+	Role         string `json:"role"`
+}
+
+// TeamMembership is the role of the member in a team, only direct memberships are captured
+type TeamMembership struct {
+	Organization string `json:"organization"`
+	Team         string `json:"team"`
+	Role         string `json:"role"`
+}
+
+// RepositoryPermission is the permission of the member as outside collaborator of a repository
+type RepositoryPermission struct {
+	// Repository is owner/name
+	Repository string `json:"repository"`
+	Permission string `json:"permission"`
+}
+
+// Snapshotter is implemented by targets that can capture the access of a member before it is deleted
+// and restore it later
+type Snapshotter interface {
+	Snapshot(ctx context.Context, member Member) (*Snapshot, error)
+	Restore(ctx context.Context, snapshot *Snapshot) error
+}
+
+// WriteSnapshot writes the snapshot as JSON into the directory and returns the path of the file
+func WriteSnapshot(dir string, snapshot *Snapshot) (string, error) {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating snapshot directory %s: %w", dir, err)
+	}
+	name := fmt.Sprintf("%s-%s.json", strings.ToLower(snapshot.Member.Login), snapshot.CreatedAt.Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return "", fmt.Errorf("error writing snapshot %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %w", path, err)
+	}
+
+	snapshot := Snapshot{}
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s", snapshot.Version, path)
+	}
+	return &snapshot, nil
+}
+
+// takeSnapshot captures the access of the member and writes it into the directory
+func takeSnapshot(ctx context.Context, target MembershipTarget, member Member, dir string) (string, error) {
+	snapshotter, ok := target.(Snapshotter)
+	if !ok {
+		return "", errors.New("target does not support snapshots")
+	}
+
+	snapshot, err := snapshotter.Snapshot(ctx, member)
+	if err != nil {
+		return "", fmt.Errorf("error capturing snapshot: %w", err)
+	}
+	snapshot.Version = SnapshotVersion
+	snapshot.CreatedAt = time.Now().UTC()
+	return WriteSnapshot(dir, snapshot)
+}
+
+// Restore grants the access of the snapshot to its member again: the member is invited into the
+// organizations with its role and re-added to the teams and repositories
+func Restore(ctx context.Context, target MembershipTarget, snapshot *Snapshot, config Config) error {
+	slog.InfoContext(ctx, "Restoring user",
+		"login", snapshot.Member.Login,
+		"createdAt", snapshot.CreatedAt,
+		"organizations", len(snapshot.Organizations),
+		"teams", len(snapshot.Teams),
+		"collaborators", len(snapshot.Collaborators))
+
+	if config.DryRun {
+		for _, o := range snapshot.Organizations {
+			slog.Info("Dry-run, would invite into organization", "login", snapshot.Member.Login, "organization", o.Organization, "role", o.Role)
+		}
+		for _, t := range snapshot.Teams {
+			slog.Info("Dry-run, would add to team", "login", snapshot.Member.Login, "organization", t.Organization, "team", t.Team, "role", t.Role)
+		}
+		for _, c := range snapshot.Collaborators {
+			slog.Info("Dry-run, would add as collaborator", "login", snapshot.Member.Login, "repository", c.Repository, "permission", c.Permission)
+		}
+		return nil
+	}
+
+	snapshotter, ok := target.(Snapshotter)
+	if !ok {
+		return errors.New("target does not support snapshots")
+	}
+	return snapshotter.Restore(ctx, snapshot)
+}
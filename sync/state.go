@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// StateVersion is the version of the state format
+const StateVersion = 1
+
+// State is persisted between runs and remembers since when members are missing in the source
+type State struct {
+	Version int `json:"version"`
+	// Pending are the members whose removal is delayed by the grace period, keyed by member ID
+	Pending map[string]PendingRemoval `json:"pending"`
+	// Inactive are the identities removed for inactivity that are not invited again, keyed by object ID
+	Inactive map[string]InactiveRemoval `json:"inactive"`
+}
+
+// newState returns an empty state
+func newState() *State {
+	return &State{
+		Version:  StateVersion,
+		Pending:  map[string]PendingRemoval{},
+		Inactive: map[string]InactiveRemoval{},
+	}
+}
+
+// PendingRemoval is a member that is missing in the source but still within the grace period
+type PendingRemoval struct {
+	Member
+	// Since is when the member was first seen missing
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason"`
+}
+
+// StateStore loads and saves the encoded state, e.g. a local file or a file in a repository
+type StateStore interface {
+	// Load returns nil if there is no state yet
+	Load(ctx context.Context) ([]byte, error)
+	Save(ctx context.Context, data []byte) error
+}
+
+// FileStateStore stores the state in a local file
+type FileStateStore struct {
+	Path string
+}
+
+// Load reads the state file, a missing file is no state
+func (s FileStateStore) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state %s: %w", s.Path, err)
+	}
+	return data, nil
+}
+
+// Save writes the state file
+func (s FileStateStore) Save(ctx context.Context, data []byte) error {
+	err := os.WriteFile(s.Path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing state %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// ReadState loads the state from the store, an empty state if there is none
+func ReadState(ctx context.Context, store StateStore) (*State, error) {
+	state := newState()
+	data, err := store.Load(ctx)
+	if err != nil || data == nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding state: %w", err)
+	}
+	if state.Version != StateVersion {
+		return nil, fmt.Errorf("unsupported state version %d", state.Version)
+	}
+	if state.Pending == nil {
+		state.Pending = map[string]PendingRemoval{}
+	}
+	if state.Inactive == nil {
+		state.Inactive = map[string]InactiveRemoval{}
+	}
+	return state, nil
+}
+
+// removed forgets the pending removal of the member once it is deleted, a failed deletion stays pending
+func (s *State) removed(id string) {
+	delete(s.Pending, id)
+}
+
+// WriteState saves the state to the store unless it encodes like the previous state, e.g. the one
+// read at the start of the run. Every save of a repository store is a commit.
+func WriteState(ctx context.Context, store StateStore, state *State, previous *State) error {
+	data, err := encodeState(state)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		old, err := encodeState(previous)
+		if err == nil && bytes.Equal(data, old) {
+			slog.DebugContext(ctx, "State unchanged, not saved")
+			return nil
+		}
+	}
+	return store.Save(ctx, data)
+}
+
+// encodeState returns the state as indented JSON, the maps are sorted by key
+func encodeState(state *State) ([]byte, error) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding state: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// applyGracePeriod replaces the delete actions of members that are missing for less than the grace period
+// with pending removals and records them in the next state. Members that are no longer missing are not
+// recorded, which cancels their pending removal.
+func applyGracePeriod(ctx context.Context, plan *Plan, state *State, next *State, gracePeriod time.Duration, now time.Time) {
+	actions := []Action{}
+	for _, a := range plan.Actions {
+		if a.Type != Delete {
+			actions = append(actions, a)
+			continue
+		}
+
+		pending, ok := state.Pending[a.ID]
+		if !ok {
+			slog.InfoContext(ctx, "User missing, removal pending", "login", a.Login, "email", a.Email, "reason", a.Reason, "removeAfter", now.Add(gracePeriod))
+			pending = PendingRemoval{Since: now}
+		}
+		pending.Member = a.member()
+		pending.Reason = a.Reason
+		next.Pending[a.ID] = pending
+
+		if now.Sub(pending.Since) >= gracePeriod {
+			a.Reason = fmt.Sprintf("%s since %s", a.Reason, pending.Since.Format(time.RFC3339))
+			actions = append(actions, a)
+			continue
+		}
+		slog.DebugContext(ctx, "User within grace period", "login", a.Login, "since", pending.Since, "removeAfter", pending.Since.Add(gracePeriod))
+		plan.Pending = append(plan.Pending, pending)
+	}
+
+	for id, pending := range state.Pending {
+		if _, ok := next.Pending[id]; !ok {
+			slog.InfoContext(ctx, "Pending removal cancelled", "login", pending.Login, "email", pending.Email, "since", pending.Since)
+		}
+	}
+
+	plan.Actions = actions
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyGracePeriod(t *testing.T) {
+	grace := 72 * time.Hour
+	bob := Action{Type: Delete, ID: "b", Login: "bob", Email: "bob@example.com", Reason: "not in source"}
+	invite := Action{Type: Invite, Email: "carol@example.com"}
+
+	tests := []struct {
+		name    string
+		actions []Action
+		pending map[string]PendingRemoval
+		// want are the remaining actions, the pending logins and the members of the next state by ID
+		want        []string
+		wantPending []string
+		wantNext    []string
+	}{
+		{
+			name:        "missing member becomes pending",
+			actions:     []Action{bob, invite},
+			want:        []string{"invite:carol@example.com"},
+			wantPending: []string{"bob"},
+			wantNext:    []string{"b"},
+		},
+		{
+			name:        "pending member within the grace period stays pending",
+			actions:     []Action{bob},
+			pending:     map[string]PendingRemoval{"b": {Member: Member{ID: "b", Login: "bob"}, Since: testNow.Add(-grace + time.Hour)}},
+			want:        []string{},
+			wantPending: []string{"bob"},
+			wantNext:    []string{"b"},
+		},
+		{
+			name:        "pending member after the grace period is deleted",
+			actions:     []Action{bob},
+			pending:     map[string]PendingRemoval{"b": {Member: Member{ID: "b", Login: "bob"}, Since: testNow.Add(-grace)}},
+			want:        []string{"delete:bob"},
+			wantPending: []string{},
+			wantNext:    []string{"b"},
+		},
+		{
+			name:        "member back in the source is no longer pending",
+			actions:     []Action{invite},
+			pending:     map[string]PendingRemoval{"b": {Member: Member{ID: "b", Login: "bob"}, Since: testNow.Add(-time.Hour)}},
+			want:        []string{"invite:carol@example.com"},
+			wantPending: []string{},
+			wantNext:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newState()
+			for id, p := range tt.pending {
+				state.Pending[id] = p
+			}
+			next := newState()
+			plan := &Plan{Actions: tt.actions}
+
+			applyGracePeriod(context.Background(), plan, state, next, grace, testNow)
+
+			if got := summary(plan.Actions); !slices.Equal(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+			pending := []string{}
+			for _, p := range plan.Pending {
+				pending = append(pending, p.Login)
+			}
+			if !slices.Equal(pending, tt.wantPending) {
+				t.Errorf("pending = %v, want %v", pending, tt.wantPending)
+			}
+			ids := []string{}
+			for id := range next.Pending {
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.wantNext) {
+				t.Errorf("next state = %v, want %v", ids, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestApplyGracePeriodKeepsSince(t *testing.T) {
+	since := testNow.Add(-time.Hour)
+	state := newState()
+	state.Pending["b"] = PendingRemoval{Member: Member{ID: "b", Login: "bob"}, Since: since}
+	next := newState()
+	plan := &Plan{Actions: []Action{{Type: Delete, ID: "b", Login: "bob", Reason: "not in source"}}}
+
+	applyGracePeriod(context.Background(), plan, state, next, time.Hour, testNow)
+
+	if !next.Pending["b"].Since.Equal(since) {
+		t.Errorf("since = %v, want %v", next.Pending["b"].Since, since)
+	}
+	if len(plan.Actions) != 1 || !strings.Contains(plan.Actions[0].Reason, since.Format(time.RFC3339)) {
+		t.Errorf("actions = %+v, want delete with the reason since %v", plan.Actions, since)
+	}
+}
+
+func TestWriteStateUnchanged(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	state := newState()
+	state.Pending["b"] = PendingRemoval{Member: Member{ID: "b", Login: "bob"}, Since: testNow}
+
+	err := WriteState(ctx, store, state, newState())
+	if err != nil || store.saves != 1 {
+		t.Fatalf("saves = %d, error = %v, want one save", store.saves, err)
+	}
+
+	previous, err := ReadState(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadState: %v", err)
+	}
+	err = WriteState(ctx, store, state, previous)
+	if err != nil || store.saves != 1 {
+		t.Errorf("saves = %d, error = %v, want no save of the unchanged state", store.saves, err)
+	}
+}
+
+func TestExecuteForgetsDeletedPending(t *testing.T) {
+	tests := []struct {
+		name      string
+		removeErr error
+		pending   bool
+		saves     int
+	}{
+		{name: "deleted member is no longer pending", saves: 1},
+		{name: "failed deletion stays pending", removeErr: errors.New("not found"), pending: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := &memoryStore{}
+			target := &fakeTarget{removeErr: tt.removeErr}
+			previous := newState()
+			previous.Pending["b"] = PendingRemoval{Member: Member{ID: "b", Login: "bob"}, Since: testNow.Add(-100 * time.Hour)}
+			state := newState()
+			state.Pending["b"] = previous.Pending["b"]
+			plan := &Plan{Identities: 1, Members: 2, Actions: []Action{{Type: Delete, ID: "b", Login: "bob"}}, state: state, previous: previous}
+
+			execute(ctx, target, plan, Config{StateStore: store})
+
+			if _, ok := state.Pending["b"]; ok != tt.pending {
+				t.Errorf("pending = %t, want %t", ok, tt.pending)
+			}
+			// the unchanged state of a failed deletion is not saved again
+			if store.saves != tt.saves {
+				t.Errorf("saves = %d, want %d", store.saves, tt.saves)
+			}
+		})
+	}
+}
@@ -2,10 +2,11 @@ package sync
 
 import (
 	"context"
-	"github.com/prodyna/sync-enterprise/azure"
-	"github.com/prodyna/sync-enterprise/github"
+	"errors"
+	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 )
 
 type ActionType int
@@ -14,126 +15,595 @@ const (
 	// Delete represents a delete action
 	Delete ActionType = iota
 	Invite ActionType = iota
+	Update ActionType = iota
+	// OrgAdd, OrgRemove and OrgRole manage the membership in an organization
+	OrgAdd    ActionType = iota
+	OrgRemove ActionType = iota
+	OrgRole   ActionType = iota
+	// TeamAdd, TeamRemove, TeamRole and TeamCreate manage the membership in a team
+	TeamAdd    ActionType = iota
+	TeamRemove ActionType = iota
+	TeamRole   ActionType = iota
+	TeamCreate ActionType = iota
+	// GroupAdd, GroupRemove and GroupCreate manage the members of a group mirroring a source group
+	GroupAdd    ActionType = iota
+	GroupRemove ActionType = iota
+	GroupCreate ActionType = iota
 )
 
-// Action represents a delete action
+func (t ActionType) String() string {
+	switch t {
+	case Delete:
+		return "delete"
+	case Invite:
+		return "invite"
+	case Update:
+		return "update"
+	case OrgAdd:
+		return "org-add"
+	case OrgRemove:
+		return "org-remove"
+	case OrgRole:
+		return "org-role"
+	case TeamAdd:
+		return "team-add"
+	case TeamRemove:
+		return "team-remove"
+	case TeamRole:
+		return "team-role"
+	case TeamCreate:
+		return "team-create"
+	case GroupAdd:
+		return "group-add"
+	case GroupRemove:
+		return "group-remove"
+	case GroupCreate:
+		return "group-create"
+	}
+	return "unknown"
+}
+
+func (t ActionType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ActionType) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "delete":
+		*t = Delete
+	case "invite":
+		*t = Invite
+	case "update":
+		*t = Update
+	case "org-add":
+		*t = OrgAdd
+	case "org-remove":
+		*t = OrgRemove
+	case "org-role":
+		*t = OrgRole
+	case "team-add":
+		*t = TeamAdd
+	case "team-remove":
+		*t = TeamRemove
+	case "team-role":
+		*t = TeamRole
+	case "team-create":
+		*t = TeamCreate
+	case "group-add":
+		*t = GroupAdd
+	case "group-remove":
+		*t = GroupRemove
+	case "group-create":
+		*t = GroupCreate
+	default:
+		return fmt.Errorf("unknown action type %s", text)
+	}
+	return nil
+}
+
+// Action represents a delete, invite, update, organization, team or group action
 type Action struct {
-	actionType  ActionType
-	displayName string
-	email       string
-	login       string
-	id          string
+	Type        ActionType `json:"type"`
+	Login       string     `json:"login,omitempty"`
+	ID          string     `json:"id,omitempty"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"displayName,omitempty"`
+	Reason      string     `json:"reason"`
+	// Groups are the source groups that granted the access of an invited user
+	Groups []string `json:"groups,omitempty"`
+	// ObjectId, UserPrincipalName, GivenName and Surname describe the identity of an invited or updated user
+	ObjectId          string `json:"objectId,omitempty"`
+	UserPrincipalName string `json:"userPrincipalName,omitempty"`
+	GivenName         string `json:"givenName,omitempty"`
+	Surname           string `json:"surname,omitempty"`
+	// Inactive is set for deletes of inactive members, their identity is not invited again
+	Inactive bool `json:"inactive,omitempty"`
+	// Organization, Team and Role are the organization, the team slug and the desired role of
+	// organization and team actions
+	Organization string `json:"organization,omitempty"`
+	Team         string `json:"team,omitempty"`
+	Role         string `json:"role,omitempty"`
+	// Group and GroupName are the ID and the name of the source group mirrored by group actions
+	Group     string `json:"group,omitempty"`
+	GroupName string `json:"groupName,omitempty"`
 }
 
-func Sync(ctx context.Context, az azure.Azure, gh github.GitHub) (err error) {
+// key identifies the user an action applies to
+func (a Action) key() string {
+	switch a.Type {
+	case Delete, Update:
+		return a.Type.String() + ":" + a.ID
+	case OrgAdd, OrgRemove, OrgRole:
+		return a.Type.String() + ":" + a.Organization + ":" + strings.ToLower(a.Login) + ":" + a.Role
+	case TeamAdd, TeamRemove, TeamRole:
+		return a.Type.String() + ":" + a.Organization + "/" + a.Team + ":" + strings.ToLower(a.Login) + ":" + a.Role
+	case TeamCreate:
+		return a.Type.String() + ":" + a.Organization + "/" + a.Team
+	case GroupAdd, GroupRemove:
+		return a.Type.String() + ":" + a.Group + ":" + strings.ToLower(a.Login)
+	case GroupCreate:
+		return a.Type.String() + ":" + a.Group
+	}
+	return a.Type.String() + ":" + strings.ToLower(a.Email)
+}
+
+// identity returns the identity an invite or update action applies
+func (a Action) identity() Identity {
+	return Identity{
+		ObjectId:          a.ObjectId,
+		Email:             a.Email,
+		DisplayName:       a.DisplayName,
+		UserPrincipalName: a.UserPrincipalName,
+		GivenName:         a.GivenName,
+		Surname:           a.Surname,
+		Groups:            a.Groups,
+	}
+}
+
+// member returns the member of the action
+func (a Action) member() Member {
+	return Member{ID: a.ID, Login: a.Login, Email: a.Email}
+}
+
+type Config struct {
+	DryRun bool
+	// MaxDeletes is the maximum number of deletions per run, 0 disables the check
+	MaxDeletes int
+	// MaxDeletePercent is the maximum percentage of members deleted per run, 0 disables the check
+	MaxDeletePercent int
+	// MaxUnmatchable is the maximum number of source identities that can not be matched, 0 disables the check
+	MaxUnmatchable int
+	// AllowMassDelete disables all guard rails for intentional large cleanups
+	AllowMassDelete bool
+	// Protection configures members that are never deleted
+	Protection Protection
+	// Policy configures identities that are not desired even though they are in the source
+	Policy Policy
+	// MatchStrategies are tried in order to find the identity of a member, defaults to mail
+	MatchStrategies []MatchStrategy
+	// FailFast stops executing actions after the first failure, otherwise all actions are tried
+	FailFast bool
+	// OrgMappings grant the members of source groups roles in organizations
+	OrgMappings []OrgMapping
+	// TeamMappings grant the members of source groups roles in teams
+	TeamMappings []TeamMapping
+	// CreateTeams creates mapped teams that do not exist
+	CreateTeams bool
+	// Groups are the source groups whose members are mirrored into groups of the target, missing groups are created
+	Groups []GroupMirror
+	// GracePeriod delays the deletion of members missing in the source, 0 deletes immediately
+	GracePeriod time.Duration
+	// StateStore persists the pending removals of the grace period between runs
+	StateStore StateStore
+	// SnapshotDir is the directory the access of members is written to before they are deleted, empty disables snapshots
+	SnapshotDir string
+}
+
+// ErrPartialFailure is returned when some actions of a sync failed
+var ErrPartialFailure = errors.New("sync partially failed")
+
+// Sync computes the actions and executes them in the same pass
+func Sync(ctx context.Context, source IdentitySource, target MembershipTarget, config Config) (*Report, error) {
 	slog.Info("Syncing users")
-	actions := []Action{}
+
+	plan, err := NewPlan(ctx, source, target, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return execute(ctx, target, plan, config)
+}
+
+// execute runs the actions of the plan against the target and reports the outcome.
+// The report is returned even if actions failed, the error then wraps ErrPartialFailure.
+func execute(ctx context.Context, target MembershipTarget, plan *Plan, config Config) (*Report, error) {
+	err := checkGuardRails(plan, config)
+	if err != nil {
+		return nil, err
+	}
+
 	delete := 0
 	invite := 0
-	stay := 0
-
-	// load github users
-	githubUsers, err := gh.Users(ctx)
+	update := 0
+	org := 0
+	team := 0
+	group := 0
+	for _, a := range plan.Actions {
+		switch a.Type {
+		case Invite:
+			invite++
+		case Delete:
+			delete++
+		case Update:
+			update++
+		case OrgAdd, OrgRemove, OrgRole:
+			org++
+		case TeamAdd, TeamRemove, TeamRole, TeamCreate:
+			team++
+		case GroupAdd, GroupRemove, GroupCreate:
+			group++
+		}
+	}
 
-	slog.InfoContext(ctx, "Checking if github users are in Azure group", "count", len(githubUsers), "group", az.Config.AzureGroup)
-	for _, githubUser := range githubUsers {
-		slog.DebugContext(ctx, "Checking user", "login", githubUser.Login, "email", githubUser.Email)
-		// check if user is in azure
-		inAzure, name, err := az.IsUserInGroup(ctx, githubUser.Email)
-		if err != nil {
-			return err
+	results := []ActionResult{}
+	errs := []error{}
+	for _, a := range plan.Actions {
+		if config.FailFast && len(errs) > 0 {
+			results = append(results, ActionResult{Action: a, Outcome: "skipped after failure", Skipped: true})
+			continue
 		}
 
-		if !inAzure {
-			slog.DebugContext(ctx, "User not in Azure", "login", githubUser.Login, "email", githubUser.Email)
-			action := &Action{
-				actionType: Delete,
-				id:         githubUser.ID,
-				email:      githubUser.Email,
-				login:      githubUser.Login,
-			}
-			if name != nil {
-				action.displayName = *name
+		var result ActionResult
+		switch a.Type {
+		case Invite:
+			result = inviteAction(ctx, target, a, config)
+		case Delete:
+			result = deleteAction(ctx, target, a, config)
+		case Update:
+			result = updateAction(ctx, target, a, config)
+		case OrgAdd, OrgRole:
+			result = orgRoleAction(ctx, target, a, config)
+		case OrgRemove:
+			result = orgRemoveAction(ctx, target, a, config)
+		case TeamAdd, TeamRole:
+			result = teamRoleAction(ctx, target, a, config)
+		case TeamRemove:
+			result = teamRemoveAction(ctx, target, a, config)
+		case TeamCreate:
+			result = teamCreateAction(ctx, target, a, config)
+		case GroupAdd:
+			result = groupAddAction(ctx, target, a, config)
+		case GroupRemove:
+			result = groupRemoveAction(ctx, target, a, config)
+		case GroupCreate:
+			result = groupCreateAction(ctx, target, a, config)
+		}
+		if result.Error != "" {
+			user := a.Email
+			switch a.Type {
+			case Invite:
+			case TeamCreate:
+				user = a.Organization + "/" + a.Team
+			case GroupCreate:
+				user = a.GroupName
+			default:
+				user = a.Login
 			}
-			actions = append(actions, *action)
-			delete++
-		} else {
-			slog.Debug("User in Azure", "login", githubUser.Login, "email", githubUser.Email, "name", *name)
-			stay++
+			errs = append(errs, fmt.Errorf("%s %s: %s", a.Type, user, result.Error))
+		}
+		if a.Type == Delete && result.Error == "" && plan.state != nil && !config.DryRun {
+			plan.state.removed(a.ID)
 		}
+		results = append(results, result)
 	}
 
-	slog.InfoContext(ctx, "Checking if Azure is is already in GitHub")
-	azureUsers, err := az.Users(ctx)
+	if plan.state != nil && !config.DryRun {
+		err = WriteState(ctx, config.StateStore, plan.state, plan.previous)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	report := newReport(plan, results, config)
+	slog.InfoContext(ctx, "Sync finished",
+		"delete", delete,
+		"invite", invite,
+		"update", update,
+		"organization", org,
+		"team", team,
+		"group", group,
+		"stay", plan.Stay,
+		"protected", len(plan.Protected),
+		"unmatchable", len(plan.Unmatchable),
+		"pending", len(plan.Pending),
+		"inactive", len(plan.Inactive),
+		"failed", len(errs))
+
+	if len(errs) > 0 {
+		return report, fmt.Errorf("%w: %d of %d actions failed: %w", ErrPartialFailure, len(errs), len(plan.Actions), errors.Join(errs...))
+	}
+	return report, nil
+}
+
+// inviteAction invites the user of the action into the target
+func inviteAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	result := ActionResult{Action: a}
+	if config.DryRun {
+		slog.Info("Dry-run, would invite user",
+			"email", a.Email,
+			"name", a.DisplayName)
+		result.Outcome = "dry-run"
+		return result
+	}
+
+	slog.InfoContext(ctx, "Inviting user",
+		"email", a.Email,
+		"name", a.DisplayName)
+	inviteResults, err := target.Invite(ctx, a.identity())
 	if err != nil {
-		return err
-	}
-	for _, azureUser := range azureUsers {
-		slog.DebugContext(ctx, "Checking user", "email", azureUser.Email, "name", azureUser.DisplayName)
-		found := false
-		for _, githubUser := range githubUsers {
-			if strings.ToLower(githubUser.Email) == strings.ToLower(azureUser.Email) {
-				found = true
-				break
-			}
+		slog.WarnContext(ctx, "Unable to invite user",
+			"email", a.Email,
+			"name", a.DisplayName,
+			"error", err)
+		result.Error = err.Error()
+		return result
+	}
+	if len(inviteResults) == 0 {
+		result.Outcome = "skipped, nothing to invite into"
+		result.Skipped = true
+		return result
+	}
+
+	outcomes := []string{}
+	failures := []string{}
+	for _, r := range inviteResults {
+		if r.Status == InviteFailed {
+			slog.WarnContext(ctx, "Invitation failed",
+				"email", r.Email,
+				"organization", r.Organization,
+				"reason", r.Reason)
+			failures = append(failures, r.Organization+": "+r.Reason)
 		}
+		outcomes = append(outcomes, r.Organization+": "+r.Status.String())
+	}
+	result.Outcome = strings.Join(outcomes, ", ")
+	result.Error = strings.Join(failures, "; ")
+	return result
+}
 
-		if !found {
-			slog.DebugContext(ctx, "User not in GitHub", "email", azureUser.Email, "name", azureUser.DisplayName)
-			action := &Action{
-				actionType:  Invite,
-				email:       azureUser.Email,
-				displayName: azureUser.DisplayName,
-			}
-			invite++
-			actions = append(actions, *action)
+// deleteAction removes the user of the action from the target
+func deleteAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	result := ActionResult{Action: a}
+	if config.DryRun {
+		slog.Info("Dry-run, would delete user",
+			"login", a.Login,
+			"email", a.Email,
+			"name", a.DisplayName)
+		result.Outcome = "dry-run"
+		return result
+	}
+
+	member := a.member()
+	snapshot := ""
+	if config.SnapshotDir != "" {
+		path, err := takeSnapshot(ctx, target, member, config.SnapshotDir)
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to capture snapshot, user is not deleted",
+				"login", a.Login,
+				"email", a.Email,
+				"error", err)
+			result.Error = err.Error()
+			return result
 		}
+		slog.InfoContext(ctx, "Snapshot captured", "login", a.Login, "path", path)
+		snapshot = ", snapshot " + path
 	}
 
-	for _, a := range actions {
-		switch a.actionType {
-		case Invite:
-			if gh.DryRun() {
-				slog.Info("Dry-run, would invite user",
-					"email", a.email,
-					"name", a.displayName)
-				continue
-			} else {
-				slog.InfoContext(ctx, "Inviting user",
-					"email", a.email,
-					"name", a.displayName)
-				err = gh.InviteUser(ctx, a.email, a.displayName)
-				if err != nil {
-					continue
-					// return err
-				}
+	slog.InfoContext(ctx, "Deleting user",
+		"login", a.Login,
+		"userId", a.ID,
+		"email", a.Email,
+		"name", a.DisplayName)
+	err := target.Remove(ctx, member)
+	if err != nil {
+		slog.WarnContext(ctx, "Unable to delete user",
+			"login", a.Login,
+			"email", a.Email,
+			"error", err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Outcome = "removed" + snapshot
+	return result
+}
+
+// updateAction updates the attributes of the member of the action in the target
+func updateAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	result := ActionResult{Action: a}
+	if config.DryRun {
+		slog.Info("Dry-run, would update user",
+			"login", a.Login,
+			"email", a.Email,
+			"name", a.DisplayName,
+			"reason", a.Reason)
+		result.Outcome = "dry-run"
+		return result
+	}
+
+	updater, ok := target.(Updater)
+	if !ok {
+		result.Error = "target does not support updates"
+		return result
+	}
+
+	slog.InfoContext(ctx, "Updating user",
+		"login", a.Login,
+		"email", a.Email,
+		"name", a.DisplayName,
+		"reason", a.Reason)
+	err := updater.Update(ctx, Member{ID: a.ID, Login: a.Login}, a.identity())
+	if err != nil {
+		slog.WarnContext(ctx, "Unable to update user",
+			"login", a.Login,
+			"email", a.Email,
+			"error", err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Outcome = "updated"
+	return result
+}
+
+// changes returns the attributes of the member that differ from the identity
+func changes(member Member, identity Identity) []string {
+	changed := []string{}
+	if member.DisplayName != identity.DisplayName {
+		changed = append(changed, "display name")
+	}
+	if identity.Email != "" && !strings.EqualFold(member.Email, identity.Email) {
+		changed = append(changed, "email")
+	}
+	return changed
+}
+
+// reconcile compares the desired identities with the current members and returns a plan with the
+// actions necessary to make the members match the identities. With updates the attributes of matched
+// members are compared with the identities as well. The activity of members is compared with now.
+func reconcile(ctx context.Context, identities []Identity, members []Member, matcher *matcher, protector *protector, policy Policy, updates bool, now time.Time) *Plan {
+	plan := &Plan{
+		Identities:  len(identities),
+		Members:     len(members),
+		Actions:     []Action{},
+		Matched:     []Match{},
+		Protected:   []ProtectedMember{},
+		Unmatchable: []UnmatchableIdentity{},
+		Pending:     []PendingRemoval{},
+		Inactive:    []InactiveMember{},
+	}
+
+	slog.InfoContext(ctx, "Checking if members are desired identities", "count", len(members))
+	found := map[int]bool{}
+	for _, member := range members {
+		slog.DebugContext(ctx, "Checking user", "login", member.Login, "email", member.Email)
+		identity := Identity{}
+		i, matchedBy, ok := matcher.match(member)
+		reason := "not in source"
+		inactive := false
+		if ok {
+			identity = identities[i]
+			if excluded, why := policy.excludes(identity); excluded {
+				ok = false
+				reason = why
+			} else if inactive, why = policy.inactive(member, now); inactive && policy.RemoveInactive {
+				ok = false
+				reason = why
+			} else if inactive {
+				slog.DebugContext(ctx, "User inactive", "login", member.Login, "email", member.Email, "reason", why)
+				plan.Inactive = append(plan.Inactive, InactiveMember{Member: member, DisplayName: identity.DisplayName, Reason: why})
 			}
-		case Delete:
-			if gh.DryRun() {
-				slog.Info("Dry-run, would delete user",
-					"login", a.login,
-					"email", a.email,
-					"name", a.displayName)
-				continue
+		}
+		if !ok {
+			if inactive {
+				// the identity is still desired, but its inactive member must not be invited again
+				found[i] = true
 			}
-
-			slog.InfoContext(ctx, "Deleting user",
-				"login", a.login,
-				"userId", a.id,
-				"email", a.email,
-				"name", a.displayName)
-			err = gh.DeleteUser(ctx, a.id)
-			if err != nil {
+			if protected, why := protector.protects(member); protected {
+				slog.InfoContext(ctx, "User not desired but protected", "login", member.Login, "email", member.Email, "reason", why)
+				plan.Protected = append(plan.Protected, ProtectedMember{Member: member, Reason: why})
 				continue
-				// return err
 			}
+			slog.DebugContext(ctx, "User not desired", "login", member.Login, "email", member.Email, "reason", reason)
+			a := Action{
+				Type:        Delete,
+				ID:          member.ID,
+				Email:       member.Email,
+				Login:       member.Login,
+				DisplayName: identity.DisplayName,
+				Reason:      reason,
+			}
+			if inactive {
+				a.Inactive = true
+				a.ObjectId = identity.ObjectId
+			}
+			plan.Actions = append(plan.Actions, a)
+			continue
+		}
+
+		slog.DebugContext(ctx, "User in source", "login", member.Login, "email", member.Email, "name", identity.DisplayName, "matchedBy", matchedBy, "groups", identity.Groups)
+		found[i] = true
+		plan.Stay++
+		plan.Matched = append(plan.Matched, Match{
+			Member:      member,
+			DisplayName: identity.DisplayName,
+			MatchedBy:   matchedBy,
+			Groups:      identity.Groups,
+		})
+
+		changed := []string{}
+		if updates {
+			changed = changes(member, identity)
+		}
+		if len(changed) > 0 {
+			slog.DebugContext(ctx, "User changed", "login", member.Login, "email", member.Email, "changed", changed)
+			plan.Actions = append(plan.Actions, Action{
+				Type:              Update,
+				ID:                member.ID,
+				Login:             member.Login,
+				Email:             identity.Email,
+				DisplayName:       identity.DisplayName,
+				Reason:            strings.Join(changed, ", ") + " changed",
+				ObjectId:          identity.ObjectId,
+				UserPrincipalName: identity.UserPrincipalName,
+				GivenName:         identity.GivenName,
+				Surname:           identity.Surname,
+				Groups:            identity.Groups,
+			})
 		}
 	}
 
-	slog.InfoContext(ctx, "Sync finished",
-		"delete", delete,
-		"invite", invite,
-		"stay", stay)
+	slog.InfoContext(ctx, "Checking if identities are already members", "count", len(identities))
+	for i, identity := range identities {
+		slog.DebugContext(ctx, "Checking user", "email", identity.Email, "name", identity.DisplayName)
+		if found[i] {
+			continue
+		}
+		if excluded, why := policy.excludes(identity); excluded {
+			slog.DebugContext(ctx, "User not desired", "email", identity.Email, "name", identity.DisplayName, "reason", why)
+			continue
+		}
+		if !matcher.matchable(identity) || identity.Email == "" {
+			reason := "no email to invite"
+			if !matcher.matchable(identity) {
+				reason = "no attribute to match"
+			}
+			slog.WarnContext(ctx, "User can not be matched or invited",
+				"name", identity.DisplayName,
+				"objectId", identity.ObjectId,
+				"upn", identity.UserPrincipalName,
+				"reason", reason)
+			plan.Unmatchable = append(plan.Unmatchable, UnmatchableIdentity{
+				ObjectId:          identity.ObjectId,
+				DisplayName:       identity.DisplayName,
+				UserPrincipalName: identity.UserPrincipalName,
+				Groups:            identity.Groups,
+				Reason:            reason,
+			})
+			continue
+		}
 
-	return nil
+		slog.DebugContext(ctx, "User not in target", "email", identity.Email, "name", identity.DisplayName)
+		plan.Actions = append(plan.Actions, Action{
+			Type:              Invite,
+			Email:             identity.Email,
+			DisplayName:       identity.DisplayName,
+			Reason:            "not in target",
+			Groups:            identity.Groups,
+			ObjectId:          identity.ObjectId,
+			UserPrincipalName: identity.UserPrincipalName,
+			GivenName:         identity.GivenName,
+			Surname:           identity.Surname,
+		})
+	}
+
+	return plan
 }
@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com", DisplayName: "Alice"}
+	bob := Identity{ObjectId: "2", Email: "bob@example.com", DisplayName: "Bob"}
+	aliceMember := Member{ID: "a", Login: "alice", Email: "Alice@example.com"}
+	bobMember := Member{ID: "b", Login: "bob", Email: "bob@example.com"}
+	botMember := Member{ID: "c", Login: "ci-bot", Email: "ci@example.com"}
+
+	tests := []struct {
+		name        string
+		identities  []Identity
+		members     []Member
+		owners      []Member
+		protection  Protection
+		policy      Policy
+		actions     []string
+		stay        int
+		protected   int
+		inactive    int
+		unmatchable int
+	}{
+		{
+			name:       "matched member stays",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember},
+			actions:    []string{},
+			stay:       1,
+		},
+		{
+			name:       "member missing in source is deleted",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember, bobMember},
+			actions:    []string{"delete:bob"},
+			stay:       1,
+		},
+		{
+			name:       "identity missing in target is invited",
+			identities: []Identity{alice, bob},
+			members:    []Member{aliceMember},
+			actions:    []string{"invite:bob@example.com"},
+			stay:       1,
+		},
+		{
+			name:       "protected login is not deleted",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember, botMember},
+			protection: Protection{Logins: []string{"CI-Bot"}},
+			actions:    []string{},
+			stay:       1,
+			protected:  1,
+		},
+		{
+			name:       "protected pattern is not deleted",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember, botMember},
+			protection: Protection{Patterns: []*regexp.Regexp{regexp.MustCompile(`-bot$`)}},
+			actions:    []string{},
+			stay:       1,
+			protected:  1,
+		},
+		{
+			name:       "owner is not deleted",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember, bobMember},
+			owners:     []Member{bobMember},
+			protection: Protection{Owners: true},
+			actions:    []string{},
+			stay:       1,
+			protected:  1,
+		},
+		{
+			name:       "disabled identity is deleted and not invited",
+			identities: []Identity{alice, {ObjectId: "2", Email: "bob@example.com", Disabled: true}},
+			members:    []Member{aliceMember, bobMember},
+			policy:     Policy{RemoveDisabled: true},
+			actions:    []string{"delete:bob"},
+			stay:       1,
+		},
+		{
+			name:        "identity without email is unmatchable",
+			identities:  []Identity{alice, {ObjectId: "2", DisplayName: "No Mail"}},
+			members:     []Member{aliceMember},
+			actions:     []string{},
+			stay:        1,
+			unmatchable: 1,
+		},
+		{
+			name:       "inactive member is reported",
+			identities: []Identity{alice},
+			members:    []Member{{ID: "a", Login: "alice", Email: "alice@example.com", LastActivity: daysAgo(100)}},
+			policy:     Policy{InactiveDays: 90},
+			actions:    []string{},
+			stay:       1,
+			inactive:   1,
+		},
+		{
+			name:       "inactive member is deleted and not invited",
+			identities: []Identity{alice},
+			members:    []Member{{ID: "a", Login: "alice", Email: "alice@example.com", LastActivity: daysAgo(100)}},
+			policy:     Policy{InactiveDays: 90, RemoveInactive: true},
+			actions:    []string{"delete:alice"},
+		},
+		{
+			name:       "protected inactive member is not invited",
+			identities: []Identity{alice},
+			members:    []Member{{ID: "a", Login: "alice", Email: "alice@example.com", LastActivity: daysAgo(100)}},
+			protection: Protection{Logins: []string{"alice"}},
+			policy:     Policy{InactiveDays: 90, RemoveInactive: true},
+			actions:    []string{},
+			protected:  1,
+		},
+		{
+			name:       "member without activity that joined recently stays",
+			identities: []Identity{alice},
+			members:    []Member{{ID: "a", Login: "alice", Email: "alice@example.com", JoinedAt: daysAgo(10)}},
+			policy:     Policy{InactiveDays: 90, RemoveInactive: true},
+			actions:    []string{},
+			stay:       1,
+		},
+		{
+			name:       "member without activity and join date stays",
+			identities: []Identity{alice},
+			members:    []Member{aliceMember},
+			policy:     Policy{InactiveDays: 90, RemoveInactive: true},
+			actions:    []string{},
+			stay:       1,
+		},
+		{
+			name:       "member without activity that joined long ago is deleted",
+			identities: []Identity{alice},
+			members:    []Member{{ID: "a", Login: "alice", Email: "alice@example.com", JoinedAt: daysAgo(200)}},
+			policy:     Policy{InactiveDays: 90, RemoveInactive: true},
+			actions:    []string{"delete:alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTarget{members: tt.members, owners: tt.owners}
+			protector := testProtector(t, target, tt.protection)
+			matcher := newMatcher(nil, tt.identities)
+
+			plan := reconcile(context.Background(), tt.identities, tt.members, matcher, protector, tt.policy, false, testNow)
+
+			if got := summary(plan.Actions); !slices.Equal(got, tt.actions) {
+				t.Errorf("actions = %v, want %v", got, tt.actions)
+			}
+			if plan.Stay != tt.stay {
+				t.Errorf("stay = %d, want %d", plan.Stay, tt.stay)
+			}
+			if len(plan.Protected) != tt.protected {
+				t.Errorf("protected = %d, want %d", len(plan.Protected), tt.protected)
+			}
+			if len(plan.Inactive) != tt.inactive {
+				t.Errorf("inactive = %d, want %d", len(plan.Inactive), tt.inactive)
+			}
+			if len(plan.Unmatchable) != tt.unmatchable {
+				t.Errorf("unmatchable = %d, want %d", len(plan.Unmatchable), tt.unmatchable)
+			}
+		})
+	}
+}
+
+func TestReconcileInactiveDelete(t *testing.T) {
+	alice := Identity{ObjectId: "1", Email: "alice@example.com"}
+	member := Member{ID: "a", Login: "alice", Email: "alice@example.com", LastActivity: daysAgo(100)}
+	target := &fakeTarget{members: []Member{member}}
+
+	plan := reconcile(context.Background(), []Identity{alice}, target.members, newMatcher(nil, []Identity{alice}),
+		testProtector(t, target, Protection{}), Policy{InactiveDays: 90, RemoveInactive: true}, false, testNow)
+
+	if len(plan.Actions) != 1 {
+		t.Fatalf("actions = %v, want one delete", summary(plan.Actions))
+	}
+	a := plan.Actions[0]
+	if !a.Inactive || a.ObjectId != "1" {
+		t.Errorf("delete inactive = %t, objectId = %q, want inactive delete of object 1", a.Inactive, a.ObjectId)
+	}
+}
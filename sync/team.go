@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// RoleMaintainer is the role of a team maintainer
+const RoleMaintainer = "maintainer"
+
+// TeamMapping grants the members of a source group a role in a team of an organization
+type TeamMapping struct {
+	Group        string
+	Organization string
+	Team         string
+	Role         string
+}
+
+// TeamMember is a direct member of a team with its role
+type TeamMember struct {
+	Member
+	Role string
+}
+
+// ErrTeamNotFound is returned by TeamTarget.TeamMembers if the team does not exist
+var ErrTeamNotFound = errors.New("team not found")
+
+// TeamTarget manages the membership and role in the teams of the organizations of the target
+type TeamTarget interface {
+	TeamMembers(ctx context.Context, org string, team string) ([]TeamMember, error)
+	SetTeamRole(ctx context.Context, org string, team string, member Member, role string) error
+	RemoveTeamMember(ctx context.Context, org string, team string, member Member) error
+	// CreateTeam creates the team without members, the creator of the team does not stay in it
+	CreateTeam(ctx context.Context, org string, team string) error
+}
+
+// higherTeamRole returns the team role with more permissions
+func higherTeamRole(a string, b string) string {
+	if a == RoleMaintainer || b == RoleMaintainer {
+		return RoleMaintainer
+	}
+	return RoleMember
+}
+
+// teamKind reconciles the direct members and roles of teams
+var teamKind = mappingKind{name: "team", add: TeamAdd, role: TeamRole, remove: TeamRemove, higher: higherTeamRole}
+
+// reconcileTeams returns the actions that make the direct members and roles of the mapped teams match
+// the source groups. Missing teams are created with CreateTeams, otherwise they are an error.
+func reconcileTeams(ctx context.Context, source IdentitySource, target MembershipTarget, members []Member, deleted map[string]bool, protector *protector, config Config) ([]Action, map[string]MappedSize, error) {
+	groups, ok := source.(GroupSource)
+	if !ok {
+		return nil, nil, errors.New("source does not support team mappings")
+	}
+	teams, ok := target.(TeamTarget)
+	if !ok {
+		return nil, nil, errors.New("target does not support team mappings")
+	}
+
+	grants := []grant{}
+	for _, mapping := range config.TeamMappings {
+		grants = append(grants, grant{group: mapping.Group, org: mapping.Organization, team: mapping.Team, role: mapping.Role})
+	}
+	load := func(ctx context.Context, org string, team string) ([]roleMember, []Action, error) {
+		current, err := teams.TeamMembers(ctx, org, team)
+		switch {
+		case errors.Is(err, ErrTeamNotFound) && config.CreateTeams:
+			slog.InfoContext(ctx, "Team does not exist", "team", org+"/"+team)
+			return []roleMember{}, []Action{{
+				Type:         TeamCreate,
+				Organization: org,
+				Team:         team,
+				Reason:       "team does not exist",
+			}}, nil
+		case err != nil:
+			return nil, nil, fmt.Errorf("error loading members of team %s/%s: %w", org, team, err)
+		}
+		list := []roleMember{}
+		for _, m := range current {
+			list = append(list, roleMember{Member: m.Member, role: m.Role})
+		}
+		return list, nil, nil
+	}
+	return reconcileMapped(ctx, teamKind, grants, groups, load, members, deleted, protector, config)
+}
+
+// teamRoleAction adds the member of the action to the team or changes its role
+func teamRoleAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Setting team role", "set team role", a.Role, func() error {
+		teams, ok := target.(TeamTarget)
+		if !ok {
+			return errors.New("target does not support team mappings")
+		}
+		return teams.SetTeamRole(ctx, a.Organization, a.Team, a.member(), a.Role)
+	})
+}
+
+// teamRemoveAction removes the member of the action from the team
+func teamRemoveAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Removing from team", "remove from team", "removed", func() error {
+		teams, ok := target.(TeamTarget)
+		if !ok {
+			return errors.New("target does not support team mappings")
+		}
+		return teams.RemoveTeamMember(ctx, a.Organization, a.Team, a.member())
+	})
+}
+
+// teamCreateAction creates the team of the action
+func teamCreateAction(ctx context.Context, target MembershipTarget, a Action, config Config) ActionResult {
+	return executeMapped(ctx, a, config, "Creating team", "create team", "created", func() error {
+		teams, ok := target.(TeamTarget)
+		if !ok {
+			return errors.New("target does not support team mappings")
+		}
+		return teams.CreateTeam(ctx, a.Organization, a.Team)
+	})
+}